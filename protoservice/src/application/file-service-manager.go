@@ -7,29 +7,99 @@ import (
 	"protoservice/src/streaming"
 )
 
+const (
+	RoleUploader = "uploader"
+	RoleAdmin    = "admin"
+)
+
+var (
+	readerPolicy   = streaming.Policy{}
+	uploaderPolicy = streaming.Policy{Roles: []string{RoleUploader, RoleAdmin}}
+	adminPolicy    = streaming.Policy{Roles: []string{RoleAdmin}}
+)
+
 type FileServiceManager struct {
 	websocketEngine *streaming.Engine
 	fileService     *fileservice.Service
 }
 
-func NewFileServiceManager(websocketEngine *streaming.Engine, rootPath, storagePath string) *FileServiceManager {
+func NewFileServiceManager(websocketEngine *streaming.Engine, storage fileservice.Storage, catalog fileservice.Catalog, sessionTimeouts fileservice.SessionTimeouts) (*FileServiceManager, error) {
 	this := new(FileServiceManager)
 	this.websocketEngine = websocketEngine
 	this.fileService = fileservice.NewService(
 		websocketEngine,
-		rootPath,
-		storagePath,
+		storage,
+		catalog,
+		sessionTimeouts,
 	)
-	this.websocketEngine.Handle("/send/file", this.fileService.HandleReceivingFileFrames)
-	this.websocketEngine.Handle("/session/open", this.fileService.HandleOpenSession)
+	// routes are registered one by one and the first failure stops the
+	// startup before the service is hooked to the engine
+	registrations := []func() error{
+		func() error {
+			return this.websocketEngine.HandleWithPolicy("/send/file", uploaderPolicy, this.fileService.HandleReceivingFileFrames)
+		},
+		func() error {
+			return this.websocketEngine.HandleWithPolicy("/session/open", uploaderPolicy, this.fileService.HandleOpenSession)
+		},
+		func() error {
+			return streaming.HandleProtoWithPolicy(this.websocketEngine, "/session/status", uploaderPolicy, this.fileService.HandleSessionStatus)
+		},
+		func() error {
+			return streaming.HandleProtoWithPolicy(this.websocketEngine, "/file/delete", adminPolicy, this.fileService.HandleFileDelete)
+		},
+		func() error {
+			return this.websocketEngine.Handle("/file/get", this.fileService.HandleSendingFile)
+		},
+		func() error {
+			return streaming.HandleProto(this.websocketEngine, "/file/info", this.fileService.HandleFileInfo)
+		},
+		func() error {
+			return streaming.HandleProto(this.websocketEngine, "/file/list", this.fileService.HandleFileList)
+		},
+		func() error {
+			return this.websocketEngine.Handle("/file/:id/meta", this.fileService.HandleFileMeta)
+		},
+	}
+	for _, register := range registrations {
+		if err := register(); err != nil {
+			this.fileService.Close()
+			return nil, err
+		}
+	}
+	this.websocketEngine.OnDisconnect(this.fileService.HandleClientDisconnect)
+	this.websocketEngine.SetTopicPolicy(fileservice.FilesTopic, uploaderPolicy)
 	go this.waitCloseSession()
 	go this.waitFileFrame()
 	go this.waitOpenSession()
-	return this
+	return this, nil
+}
+
+func (this *FileServiceManager) Close() {
+	this.fileService.Close()
 }
 
 func (this *FileServiceManager) waitCloseSession() {
 	for event := range this.fileService.SessionClosingEventChannel {
+		if event.Expired {
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE-MANAGER [WARNING]: session [%s] expired. [error: %s]",
+					event.SessionUUID,
+					event.Error.Error(),
+				),
+			)
+			continue
+		}
+		if event.Context == nil {
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE-MANAGER [WARNING]: session [%s] closed without client. [error: %s]",
+					event.SessionUUID,
+					event.Error.Error(),
+				),
+			)
+			continue
+		}
 		if event.OK {
 			log.Println(
 				fmt.Sprintf(
@@ -89,6 +159,9 @@ func (this *FileServiceManager) waitFileFrame() {
 					event.Error.Error(),
 				),
 			)
+			if fileservice.IsRecoverableFrameError(event.Error) {
+				continue
+			}
 			this.websocketEngine.CloseConnectionClient(event.Context.ClientRemoteAddress)
 		}
 	}
@@ -0,0 +1,162 @@
+package application
+
+import (
+	"mime"
+	"net/http"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fileResponce struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+	Checksum    string `json:"sha256"`
+}
+
+func newFileResponce(record fileservice.FileRecord) fileResponce {
+	return fileResponce{
+		ID:          record.ID,
+		Name:        record.Name,
+		Size:        record.Size,
+		ContentType: record.ContentType,
+		Checksum:    record.Checksum,
+	}
+}
+
+func (this *HttpEngine) uploadFile(context *gin.Context) {
+	var fileSize uint64
+	if context.Request.ContentLength > 0 {
+		fileSize = uint64(context.Request.ContentLength)
+	}
+	contentType := context.ContentType()
+	var principal *streaming.Principal
+	if value, exist := context.Get(principalKey); exist {
+		principal, _ = value.(*streaming.Principal)
+	}
+	record, err := this.fileServiceManager.fileService.UploadFile(
+		streaming.RemoteAddress(context.Request.RemoteAddr),
+		principal,
+		context.Param("name"),
+		contentType,
+		fileSize,
+		context.GetHeader("X-Checksum-Sha256"),
+		context.Request.Body,
+	)
+	if err != nil {
+		context.AbortWithStatusJSON(httpStatusByError(err), gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusCreated, newFileResponce(record))
+}
+
+// downloadFile serves GET and HEAD, including Range requests.
+func (this *HttpEngine) downloadFile(context *gin.Context) {
+	reader, record, err := this.fileServiceManager.fileService.OpenFile(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(httpStatusByError(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+	if record.ContentType != "" {
+		context.Header("Content-Type", record.ContentType)
+	}
+	if record.Checksum != "" {
+		context.Header("ETag", `"`+record.Checksum+`"`)
+	}
+	context.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": record.Name}))
+	http.ServeContent(context.Writer, context.Request, record.Name, record.UploadedAt, reader)
+}
+
+func (this *HttpEngine) deleteFile(context *gin.Context) {
+	err := this.fileServiceManager.fileService.DeleteFile(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(httpStatusByError(err), gin.H{"error": err.Error()})
+		return
+	}
+	context.Status(http.StatusNoContent)
+}
+
+type fileListResponce struct {
+	Files      []fileResponce `json:"files"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func (this *HttpEngine) listFiles(context *gin.Context) {
+	filter, err := fileFilterFromQuery(context)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := 0
+	if value := context.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	records, nextCursor, err := this.fileServiceManager.fileService.ListFiles(filter, context.Query("cursor"), limit)
+	if err != nil {
+		context.AbortWithStatusJSON(httpStatusByError(err), gin.H{"error": err.Error()})
+		return
+	}
+	responce := fileListResponce{
+		Files:      make([]fileResponce, 0, len(records)),
+		NextCursor: nextCursor,
+	}
+	for _, record := range records {
+		responce.Files = append(responce.Files, newFileResponce(record))
+	}
+	context.JSON(http.StatusOK, responce)
+}
+
+func fileFilterFromQuery(context *gin.Context) (fileservice.FileFilter, error) {
+	var err error
+	filter := fileservice.FileFilter{
+		NamePrefix:      context.Query("name_prefix"),
+		ContentType:     context.Query("content_type"),
+		UploaderAddress: context.Query("uploader"),
+	}
+	if value := context.Query("min_size"); value != "" {
+		if filter.MinSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+	if value := context.Query("max_size"); value != "" {
+		if filter.MaxSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+	if value := context.Query("uploaded_after"); value != "" {
+		if filter.UploadedAfter, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := context.Query("uploaded_before"); value != "" {
+		if filter.UploadedBefore, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func httpStatusByError(err error) int {
+	switch err {
+	case fileservice.ErrorFileIsntExist:
+		return http.StatusNotFound
+	case fileservice.ErrorFileIdIsntValid,
+		fileservice.ErrorFileNameIsntValid,
+		fileservice.ErrorChecksumIsntValid,
+		fileservice.ErrorFileSizeMismatch,
+		fileservice.ErrorFileChecksumMismatch,
+		fileservice.ErrorCursorIsntValid:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
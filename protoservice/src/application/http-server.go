@@ -3,6 +3,7 @@ package application
 import (
 	"log"
 	"net/http"
+	"protoservice/src/fileservice"
 	"protoservice/src/streaming"
 
 	"github.com/gin-gonic/gin"
@@ -15,25 +16,62 @@ type HttpEngine struct {
 	fileServiceManager *FileServiceManager
 }
 
-func NewHttpEngine(websocketEngine *streaming.Engine, port string, rootPath, storagePath string) *HttpEngine {
+func NewHttpEngine(websocketEngine *streaming.Engine, port string, storage fileservice.Storage, catalog fileservice.Catalog, sessionTimeouts fileservice.SessionTimeouts) (*HttpEngine, error) {
 	engine := gin.New()
 	this := new(HttpEngine)
 	this.HttpEngine = engine
 	this.websocketEngine = websocketEngine
-	this.fileServiceManager = NewFileServiceManager(
+	fileServiceManager, err := NewFileServiceManager(
 		websocketEngine,
-		rootPath,
-		storagePath,
+		storage,
+		catalog,
+		sessionTimeouts,
 	)
+	if err != nil {
+		return nil, err
+	}
+	this.fileServiceManager = fileServiceManager
 	//
 	engine.GET("/ws", this.openWebsocket)
+	engine.GET("/files", this.authorize(readerPolicy), this.listFiles)
+	engine.PUT("/files/:name", this.authorize(uploaderPolicy), this.uploadFile)
+	engine.GET("/files/:id", this.authorize(readerPolicy), this.downloadFile)
+	engine.HEAD("/files/:id", this.authorize(readerPolicy), this.downloadFile)
+	engine.DELETE("/files/:id", this.authorize(adminPolicy), this.deleteFile)
 	this.RunHttpEngine = func() {
 		err := engine.Run(port)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
-	return this
+	return this, nil
+}
+
+func (this *HttpEngine) Close() {
+	this.fileServiceManager.Close()
+}
+
+// principalKey keeps the principal authenticated by authorize in the gin
+// context of the request.
+const principalKey = "principal"
+
+// authorize applies a websocket handler policy to an HTTP route, the
+// request is authenticated with the websocket engine authenticator.
+func (this *HttpEngine) authorize(policy streaming.Policy) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		principal, err := this.websocketEngine.Authenticate(context.Request)
+		if err != nil {
+			context.Header("WWW-Authenticate", `Bearer realm="protoservice"`)
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": streaming.ErrorClientIsntAuthenticated.Error()})
+			return
+		}
+		if !this.websocketEngine.Authorize(principal, policy) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": streaming.ErrorAccessIsDenied.Error()})
+			return
+		}
+		context.Set(principalKey, principal)
+		context.Next()
+	}
 }
 
 func (this *HttpEngine) openWebsocket(context *gin.Context) {
@@ -41,6 +79,11 @@ func (this *HttpEngine) openWebsocket(context *gin.Context) {
 		http.ResponseWriter(context.Writer),
 		context.Request,
 	)
+	if err == streaming.ErrorClientIsntAuthenticated {
+		context.Header("WWW-Authenticate", `Bearer realm="protoservice"`)
+		context.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		context.AbortWithStatus(http.StatusLocked)
 		return
@@ -0,0 +1,172 @@
+package fileservice
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxListScan bounds the number of index entries a single List call reads,
+// so a selective filter over a large catalog returns a short page and a
+// cursor instead of walking the whole index.
+const maxListScan = 10000
+
+var (
+	boltFilesBucket     = []byte("files")
+	boltUploadedAtIndex = []byte("files-by-uploaded-at")
+)
+
+type BoltCatalog struct {
+	db *bolt.DB
+}
+
+func NewBoltCatalog(path string) (*BoltCatalog, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		files, err := tx.CreateBucketIfNotExists(boltFilesBucket)
+		if err != nil {
+			return err
+		}
+		if tx.Bucket(boltUploadedAtIndex) != nil {
+			return nil
+		}
+		index, err := tx.CreateBucket(boltUploadedAtIndex)
+		if err != nil {
+			return err
+		}
+		return files.ForEach(func(id, value []byte) error {
+			record := FileRecord{}
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			return index.Put(uploadedAtIndexKey(record), id)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	this := new(BoltCatalog)
+	this.db = db
+	return this, nil
+}
+
+func uploadedAtIndexKey(record FileRecord) []byte {
+	key := make([]byte, 8, 8+len(record.ID))
+	binary.BigEndian.PutUint64(key, uint64(record.UploadedAt.UnixNano()))
+	return append(key, record.ID...)
+}
+
+func (this *BoltCatalog) Put(record FileRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return this.db.Update(func(tx *bolt.Tx) error {
+		files := tx.Bucket(boltFilesBucket)
+		index := tx.Bucket(boltUploadedAtIndex)
+		if previous := files.Get([]byte(record.ID)); previous != nil {
+			previousRecord := FileRecord{}
+			if err := json.Unmarshal(previous, &previousRecord); err != nil {
+				return err
+			}
+			if err := index.Delete(uploadedAtIndexKey(previousRecord)); err != nil {
+				return err
+			}
+		}
+		if err := index.Put(uploadedAtIndexKey(record), []byte(record.ID)); err != nil {
+			return err
+		}
+		return files.Put([]byte(record.ID), value)
+	})
+}
+
+func (this *BoltCatalog) Get(id string) (FileRecord, error) {
+	record := FileRecord{}
+	err := this.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltFilesBucket).Get([]byte(id))
+		if value == nil {
+			return ErrorRecordIsntExist
+		}
+		return json.Unmarshal(value, &record)
+	})
+	return record, err
+}
+
+func (this *BoltCatalog) Delete(id string) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		files := tx.Bucket(boltFilesBucket)
+		value := files.Get([]byte(id))
+		if value == nil {
+			return ErrorRecordIsntExist
+		}
+		record := FileRecord{}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltUploadedAtIndex).Delete(uploadedAtIndexKey(record)); err != nil {
+			return err
+		}
+		return files.Delete([]byte(id))
+	})
+}
+
+func (this *BoltCatalog) List(filter FileFilter, cursor string, limit int) ([]FileRecord, string, error) {
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", ErrorCursorIsntValid
+	}
+	records := make([]FileRecord, 0, limit)
+	nextCursor := ""
+	err = this.db.View(func(tx *bolt.Tx) error {
+		files := tx.Bucket(boltFilesBucket)
+		iterator := tx.Bucket(boltUploadedAtIndex).Cursor()
+		key, id := iterator.First()
+		if len(after) != 0 {
+			key, id = iterator.Seek(after)
+			if key != nil && bytes.Equal(key, after) {
+				key, id = iterator.Next()
+			}
+		} else if !filter.UploadedAfter.IsZero() {
+			key, id = iterator.Seek(uploadedAtIndexKey(FileRecord{
+				UploadedAt: filter.UploadedAfter.Add(time.Nanosecond),
+			}))
+		}
+		for scanned := 0; key != nil; key, id = iterator.Next() {
+			if !filter.UploadedBefore.IsZero() && int64(binary.BigEndian.Uint64(key)) >= filter.UploadedBefore.UnixNano() {
+				return nil
+			}
+			if len(records) == limit || scanned == maxListScan {
+				nextCursor = base64.RawURLEncoding.EncodeToString(after)
+				return nil
+			}
+			scanned++
+			after = key
+			record := FileRecord{}
+			if err := json.Unmarshal(files.Get(id), &record); err != nil {
+				return err
+			}
+			if filter.Match(record) {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return records, nextCursor, nil
+}
+
+func (this *BoltCatalog) Close() error {
+	return this.db.Close()
+}
@@ -0,0 +1,73 @@
+package fileservice
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type FileRecord struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	StorageName     string    `json:"storage_name"`
+	Size            int64     `json:"size"`
+	ContentType     string    `json:"content_type"`
+	Checksum        string    `json:"checksum"`
+	UploaderAddress string    `json:"uploader_address"`
+	Owner           string    `json:"owner,omitempty"`
+	SessionUUID     string    `json:"session_uuid"`
+	CreatedAt       time.Time `json:"created_at"`
+	UploadedAt      time.Time `json:"uploaded_at"`
+}
+
+// FileFilter narrows a catalog listing; zero fields don't filter.
+type FileFilter struct {
+	NamePrefix      string
+	ContentType     string
+	MinSize         int64
+	MaxSize         int64
+	UploaderAddress string
+	UploadedAfter   time.Time
+	UploadedBefore  time.Time
+}
+
+func (this FileFilter) Match(record FileRecord) bool {
+	if !strings.HasPrefix(record.Name, this.NamePrefix) {
+		return false
+	}
+	if this.ContentType != "" && record.ContentType != this.ContentType {
+		return false
+	}
+	if record.Size < this.MinSize {
+		return false
+	}
+	if this.MaxSize != 0 && record.Size > this.MaxSize {
+		return false
+	}
+	if this.UploaderAddress != "" && record.UploaderAddress != this.UploaderAddress {
+		return false
+	}
+	if !this.UploadedAfter.IsZero() && !record.UploadedAt.After(this.UploadedAfter) {
+		return false
+	}
+	if !this.UploadedBefore.IsZero() && !record.UploadedAt.Before(this.UploadedBefore) {
+		return false
+	}
+	return true
+}
+
+type Catalog interface {
+	Put(record FileRecord) error
+	Get(id string) (FileRecord, error)
+	Delete(id string) error
+	// List returns up to limit records matching filter in upload order,
+	// starting after cursor, and the cursor of the next page ("" when the
+	// listing is exhausted).
+	List(filter FileFilter, cursor string, limit int) ([]FileRecord, string, error)
+	Close() error
+}
+
+var (
+	ErrorRecordIsntExist = errors.New("Error: file record isn't exist in catalog")
+	ErrorCursorIsntValid = errors.New("Error: listing cursor isn't valid")
+)
@@ -0,0 +1,110 @@
+package fileservice
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"protoservice/src/streaming"
+)
+
+const (
+	defaultDownloadChunkSize = 64 * 1024
+	maxDownloadChunkSize     = 4 * 1024 * 1024
+)
+
+var (
+	ErrorOffsetIsOutOfRange = errors.New("Error: offset is out of the file range")
+	ErrorFileIsTruncated    = errors.New("Error: stored file is shorter than its catalog record")
+)
+
+func (this *Service) HandleSendingFile(context *streaming.Context) {
+	downloadRequest := new(FileDownloadRequest)
+	err := context.Bind(downloadRequest)
+	if err != nil {
+		this.replyDownloadError(context, downloadRequest, err)
+		return
+	}
+	reader, record, err := this.OpenFile(downloadRequest.GetFileId())
+	if err != nil {
+		this.replyDownloadError(context, downloadRequest, err)
+		return
+	}
+	defer reader.Close()
+	offset := downloadRequest.GetOffset()
+	if offset > uint64(record.Size) {
+		this.replyDownloadError(context, downloadRequest, ErrorOffsetIsOutOfRange)
+		return
+	}
+	if _, err := reader.Seek(int64(offset), io.SeekStart); err != nil {
+		this.replyDownloadError(context, downloadRequest, err)
+		return
+	}
+	chunkSize := downloadRequest.GetChunkSize()
+	if chunkSize == 0 {
+		chunkSize = defaultDownloadChunkSize
+	}
+	if chunkSize > maxDownloadChunkSize {
+		chunkSize = maxDownloadChunkSize
+	}
+	chunk := make([]byte, chunkSize)
+	// the stream never goes past the cataloged size, even if the stored
+	// object is longer
+	limitedReader := io.LimitReader(reader, record.Size-int64(offset))
+	for {
+		n, err := io.ReadFull(limitedReader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			this.replyDownloadError(context, downloadRequest, err)
+			return
+		}
+		lastFrame := offset+uint64(n) >= uint64(record.Size)
+		if err != nil && !lastFrame {
+			this.replyDownloadError(context, downloadRequest, ErrorFileIsTruncated)
+			return
+		}
+		err = context.Reply(&FileDownloadResponce{
+			FileId:         downloadRequest.GetFileId(),
+			FileName:       record.Name,
+			FileSize:       uint64(record.Size),
+			Offset:         offset,
+			LastFrame:      lastFrame,
+			StreamingFrame: chunk[:n],
+			Crc32C:         crc32.Checksum(chunk[:n], crc32cTable),
+		})
+		if err != nil {
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE [ERROR]: Sending the file [%s] to the client [%s] failed. [error: %s]",
+					downloadRequest.GetFileId(),
+					string(context.ClientRemoteAddress),
+					err.Error(),
+				),
+			)
+			return
+		}
+		offset += uint64(n)
+		if lastFrame {
+			break
+		}
+	}
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [OK]: Sending the file [%s] to the client [%s] completed successfully",
+			downloadRequest.GetFileId(),
+			string(context.ClientRemoteAddress),
+		),
+	)
+}
+
+func (this *Service) replyDownloadError(context *streaming.Context, downloadRequest *FileDownloadRequest, err error) {
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [ERROR]: Sending the file [%s] to the client [%s] failed. [error: %s]",
+			downloadRequest.GetFileId(),
+			string(context.ClientRemoteAddress),
+			err.Error(),
+		),
+	)
+	context.ReplyError(err)
+}
@@ -0,0 +1,263 @@
+package fileservice
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"protoservice/src/streaming"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	uploadChunkSize  = 64 * 1024
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// FilesTopic is the topic of the events of all files.
+const FilesTopic = "files"
+
+var (
+	ErrorFileIdIsntValid = errors.New("Error: file id isn't valid")
+)
+
+// StatFile resolves the id returned by the handshake (the session UUID of
+// the upload) to the catalog record of the stored file.
+func (this *Service) StatFile(fileID string) (FileRecord, error) {
+	if _, err := uuid.Parse(fileID); err != nil {
+		return FileRecord{}, ErrorFileIdIsntValid
+	}
+	record, err := this.Catalog.Get(fileID)
+	if err == ErrorRecordIsntExist {
+		return FileRecord{}, ErrorFileIsntExist
+	}
+	if err != nil {
+		return FileRecord{}, err
+	}
+	return record, nil
+}
+
+func (this *Service) OpenFile(fileID string) (io.ReadSeekCloser, FileRecord, error) {
+	record, err := this.StatFile(fileID)
+	if err != nil {
+		return nil, FileRecord{}, err
+	}
+	reader, err := this.Storage.Open(record.StorageName)
+	if err != nil {
+		return nil, FileRecord{}, err
+	}
+	return reader, record, nil
+}
+
+func (this *Service) DeleteFile(fileID string) error {
+	record, err := this.StatFile(fileID)
+	if err != nil {
+		return err
+	}
+	err = this.Storage.Delete(record.StorageName)
+	if err == ErrorFileIsntExist {
+		err = nil
+	}
+	if err == nil {
+		err = this.Catalog.Delete(fileID)
+	}
+	if err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"FILESERVICE [ERROR]: Deleting the file [%s] failed. [error: %s]",
+				fileID,
+				err.Error(),
+			),
+		)
+		return err
+	}
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [OK]: Deleting the file [%s] completed successfully",
+			fileID,
+		),
+	)
+	this.publishFileEvent(FileEvent_DELETED, record)
+	return nil
+}
+
+// FileTopic is the topic of the events of one file, the file ID is known
+// to the uploader from the handshake, so the upload can be watched.
+func FileTopic(fileID string) string {
+	return FilesTopic + "/" + fileID
+}
+
+func (this *Service) publishFileEvent(kind FileEvent_Kind, record FileRecord) {
+	event := &FileEvent{
+		Kind: kind,
+		File: newFileInfoResponce(record),
+	}
+	for _, topic := range []string{FilesTopic, FileTopic(record.ID)} {
+		if _, err := this.websocketEngine.Publish(topic, event); err != nil {
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE [ERROR]: Publishing the event [%s] of the file [%s] failed. [error: %s]",
+					kind.String(),
+					record.ID,
+					err.Error(),
+				),
+			)
+		}
+	}
+}
+
+func (this *Service) HandleFileDelete(context *streaming.Context, deleteRequest *FileDeleteRequest) (*FileDeleteResponce, error) {
+	if err := this.DeleteFile(deleteRequest.GetFileId()); err != nil {
+		return nil, err
+	}
+	return &FileDeleteResponce{
+		FileId: deleteRequest.GetFileId(),
+	}, nil
+}
+
+func (this *Service) HandleFileInfo(context *streaming.Context, infoRequest *FileInfoRequest) (*FileInfoResponce, error) {
+	record, err := this.StatFile(infoRequest.GetFileId())
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfoResponce(record), nil
+}
+
+// HandleFileMeta serves the info of the file named by the ":id" parameter
+// of the route, e.g. "/file/:id/meta".
+func (this *Service) HandleFileMeta(context *streaming.Context) {
+	responce, err := this.HandleFileInfo(context, &FileInfoRequest{
+		FileId: context.Param("id"),
+	})
+	if err != nil {
+		context.ReplyError(err)
+		return
+	}
+	context.Reply(responce)
+}
+
+func (this *Service) ListFiles(filter FileFilter, cursor string, limit int) ([]FileRecord, string, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	return this.Catalog.List(filter, cursor, limit)
+}
+
+func (this *Service) HandleFileList(context *streaming.Context, listRequest *FileListRequest) (*FileListResponce, error) {
+	filter := FileFilter{
+		NamePrefix:      listRequest.GetNamePrefix(),
+		ContentType:     listRequest.GetContentType(),
+		MinSize:         int64(listRequest.GetMinSize()),
+		MaxSize:         int64(listRequest.GetMaxSize()),
+		UploaderAddress: listRequest.GetUploaderAddress(),
+	}
+	if listRequest.GetUploadedAfter() != nil {
+		filter.UploadedAfter = listRequest.GetUploadedAfter().AsTime()
+	}
+	if listRequest.GetUploadedBefore() != nil {
+		filter.UploadedBefore = listRequest.GetUploadedBefore().AsTime()
+	}
+	records, nextCursor, err := this.ListFiles(filter, listRequest.GetCursor(), int(listRequest.GetLimit()))
+	if err != nil {
+		return nil, err
+	}
+	responce := &FileListResponce{
+		Files:      make([]*FileInfoResponce, 0, len(records)),
+		NextCursor: nextCursor,
+	}
+	for _, record := range records {
+		responce.Files = append(responce.Files, newFileInfoResponce(record))
+	}
+	return responce, nil
+}
+
+func newFileInfoResponce(record FileRecord) *FileInfoResponce {
+	return &FileInfoResponce{
+		FileId:          record.ID,
+		FileName:        record.Name,
+		FileSize:        uint64(record.Size),
+		ContentType:     record.ContentType,
+		Sha256:          record.Checksum,
+		UploaderAddress: record.UploaderAddress,
+		SessionUuid:     record.SessionUUID,
+		CreatedAt:       timestamppb.New(record.CreatedAt),
+		UploadedAt:      timestamppb.New(record.UploadedAt),
+	}
+}
+
+// UploadFile stores a file read from body in one call, using the same
+// session bookkeeping as the websocket upload. The declared size and sha256
+// (hex) are optional and verified like the handshake ones.
+func (this *Service) UploadFile(remoteAddress streaming.RemoteAddress, principal *streaming.Principal, fileName, contentType string, fileSize uint64, sha256 string, body io.Reader) (FileRecord, error) {
+	digest, err := hex.DecodeString(sha256)
+	if err != nil {
+		return FileRecord{}, ErrorChecksumIsntValid
+	}
+	session, err := newSession(remoteAddress, this.Storage, &HandshakeRequest{
+		RemoteAddress: string(remoteAddress),
+		FileName:      fileName,
+		FileSize:      fileSize,
+		ContentType:   contentType,
+		Sha256:        digest,
+	})
+	if err != nil {
+		return FileRecord{}, err
+	}
+	if principal != nil {
+		session.principal = principal.Subject
+	}
+	if err := this.poolSession.push(session); err != nil {
+		return FileRecord{}, err
+	}
+	defer this.poolSession.delete(uuidCode(session.sessionUUID.String()))
+	chunk := make([]byte, uploadChunkSize)
+	for {
+		n, readErr := body.Read(chunk)
+		if n > 0 {
+			if _, err := session.appendFileFrame(nil, nil, chunk[:n]); err != nil {
+				session.abort()
+				return FileRecord{}, this.logUploadError(remoteAddress, session, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			session.abort()
+			return FileRecord{}, this.logUploadError(remoteAddress, session, readErr)
+		}
+	}
+	record, err := this.finalizeSession(session)
+	if err != nil {
+		return FileRecord{}, this.logUploadError(remoteAddress, session, err)
+	}
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [OK]: Uploading the file [%s] from the client [%s] completed successfully. [uuid: %s]",
+			session.fileName,
+			string(remoteAddress),
+			session.sessionUUID.String(),
+		),
+	)
+	return record, nil
+}
+
+func (this *Service) logUploadError(remoteAddress streaming.RemoteAddress, session *session, err error) error {
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [ERROR]: Uploading the file [%s] from the client [%s] failed. [uuid: %s, error: %s]",
+			session.fileName,
+			string(remoteAddress),
+			session.sessionUUID.String(),
+			err.Error(),
+		),
+	)
+	return err
+}
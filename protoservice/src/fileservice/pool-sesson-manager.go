@@ -2,7 +2,9 @@ package fileservice
 
 import (
 	"errors"
+	"protoservice/src/streaming"
 	"sync"
+	"time"
 )
 
 type (
@@ -24,8 +26,8 @@ func newPoolSessionManager() *poolSessionManager {
 func (this *poolSessionManager) push(session *session) error {
 	this.mx.Lock()
 	defer this.mx.Unlock()
-	if session, exist := this.pool[uuidCode(session.sessionUUID.String())]; exist {
-		return errors.New("Session [" + session.sessionUUID.String() + "] is exist with client [" + string(session.remoteClientAddress) + "]")
+	if exist, ok := this.pool[uuidCode(session.sessionUUID.String())]; ok {
+		return errors.New("Session [" + exist.sessionUUID.String() + "] is exist with client [" + string(exist.remoteClientAddress) + "]")
 	} else {
 		this.pool[uuidCode(session.sessionUUID.String())] = session
 		return nil
@@ -52,3 +54,31 @@ func (this *poolSessionManager) delete(uuidCode uuidCode) error {
 		return errors.New("Session none exist.")
 	}
 }
+
+func (this *poolSessionManager) ownedBy(remoteClientAddress streaming.RemoteAddress) []*session {
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	owned := make([]*session, 0)
+	for _, session := range this.pool {
+		if session.remoteAddress() == remoteClientAddress {
+			owned = append(owned, session)
+		}
+	}
+	return owned
+}
+
+// popExpired removes and returns the sessions that were idle longer than
+// idleTimeout or live longer than maxLifetime. Zero durations disable the
+// corresponding limit.
+func (this *poolSessionManager) popExpired(idleTimeout, maxLifetime time.Duration, now time.Time) []*session {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	expired := make([]*session, 0)
+	for uuidCode, session := range this.pool {
+		if session.isExpired(idleTimeout, maxLifetime, now) {
+			expired = append(expired, session)
+			delete(this.pool, uuidCode)
+		}
+	}
+	return expired
+}
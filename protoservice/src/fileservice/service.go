@@ -1,45 +1,183 @@
 package fileservice
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"protoservice/src/streaming"
-	"strings"
-
-	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
 )
 
 type Event struct {
-	Context *streaming.Context
-	OK      bool
-	Error   error
+	Context     *streaming.Context
+	SessionUUID string
+	OK          bool
+	Expired     bool
+	Error       error
+}
+
+// SessionTimeouts limits how long an unfinished session is kept: Idle since
+// its last frame and MaxLifetime since it was opened. Zero disables a limit.
+type SessionTimeouts struct {
+	Idle        time.Duration
+	MaxLifetime time.Duration
+}
+
+var (
+	ErrorSessionOwnerMismatch  = errors.New("Error: session belongs to another client")
+	ErrorFrameOffsetMismatch   = errors.New("Error: frame offset doesn't match the committed bytes")
+	ErrorFrameChecksumMismatch = errors.New("Error: frame crc32c doesn't match the frame bytes")
+	ErrorFileChecksumMismatch  = errors.New("Error: file sha256 doesn't match the declared one")
+	ErrorChecksumIsntValid     = errors.New("Error: declared sha256 must be 32 bytes long")
+	ErrorSessionIsClosed       = errors.New("Error: session is already closed")
+	ErrorSessionIsExpired      = errors.New("Error: session is expired")
+	ErrorSessionIsPaused       = errors.New("Error: session is paused until the client reattaches")
+	ErrorSessionIsntPaused     = errors.New("Error: only a paused resumable session can be reattached")
+	ErrorConnectionIsLost      = errors.New("Error: connection with the session owner is lost")
+)
+
+const (
+	minSessionReapInterval = 10 * time.Millisecond
+	maxSessionReapInterval = time.Minute
+)
+
+// IsRecoverableFrameError reports whether the client may resend the frame
+// within the same session after receiving err.
+func IsRecoverableFrameError(err error) bool {
+	return err == ErrorFrameOffsetMismatch || err == ErrorFrameChecksumMismatch || err == ErrorSessionIsPaused
 }
 
 type Service struct {
 	websocketEngine              *streaming.Engine
 	poolSession                  *poolSessionManager
-	RootPath                     string
-	StoragePath                  string
+	Storage                      Storage
+	Catalog                      Catalog
+	SessionTimeouts              SessionTimeouts
 	SessionOpeningEventChannel   chan Event
 	SessionClosingEventChannel   chan Event
 	FileFrameReceiveEventChannel chan Event
+	closing                      chan struct{}
+	closeOnce                    sync.Once
 }
 
-func NewService(websocketEngine *streaming.Engine, rootPath, storagePath string) *Service {
+func NewService(websocketEngine *streaming.Engine, storage Storage, catalog Catalog, sessionTimeouts SessionTimeouts) *Service {
 	this := new(Service)
 	this.websocketEngine = websocketEngine
 	this.poolSession = newPoolSessionManager()
-	this.RootPath = rootPath
-	this.StoragePath = storagePath
+	this.Storage = storage
+	this.Catalog = catalog
+	this.SessionTimeouts = sessionTimeouts
 	this.SessionClosingEventChannel = make(chan Event)
 	this.SessionOpeningEventChannel = make(chan Event)
 	this.FileFrameReceiveEventChannel = make(chan Event)
+	this.closing = make(chan struct{})
+	go this.reapExpiredSessions()
 	return this
 }
 
+// HandleClientDisconnect pauses the resumable sessions of a dropped
+// connection and aborts the others, it's meant for Engine.OnDisconnect.
+func (this *Service) HandleClientDisconnect(clientRemoteAddress streaming.RemoteAddress) {
+	for _, session := range this.poolSession.ownedBy(clientRemoteAddress) {
+		if session.resumable {
+			session.pause()
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE [WARNING]: Connection with client [%s] is lost, session [%s] is paused. [committed: %d]",
+					string(clientRemoteAddress),
+					session.sessionUUID.String(),
+					session.committedBytes(),
+				),
+			)
+			continue
+		}
+		this.abortSession(session)
+		log.Println(
+			fmt.Sprintf(
+				"FILESERVICE [WARNING]: Connection with client [%s] is lost, session [%s] is aborted.",
+				string(clientRemoteAddress),
+				session.sessionUUID.String(),
+			),
+		)
+		// the hook may run on a goroutine that itself reads the event
+		// channels (the manager closing a connection), so don't block it;
+		// the event is dropped once the service is closed
+		go func(event Event) {
+			select {
+			case this.SessionClosingEventChannel <- event:
+			case <-this.closing:
+			}
+		}(Event{
+			SessionUUID: session.sessionUUID.String(),
+			OK:          false,
+			Error:       ErrorConnectionIsLost,
+		})
+	}
+}
+
+func (this *Service) reapExpiredSessions() {
+	interval := maxSessionReapInterval
+	for _, timeout := range []time.Duration{this.SessionTimeouts.Idle, this.SessionTimeouts.MaxLifetime} {
+		if timeout != 0 && timeout/2 < interval {
+			interval = timeout / 2
+		}
+	}
+	if interval < minSessionReapInterval {
+		interval = minSessionReapInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-this.closing:
+			return
+		case now = <-ticker.C:
+		}
+		expired := this.poolSession.popExpired(this.SessionTimeouts.Idle, this.SessionTimeouts.MaxLifetime, now)
+		for _, session := range expired {
+			if err := session.abort(); err != nil {
+				log.Println(
+					fmt.Sprintf(
+						"FILESERVICE [ERROR]: Aborting of the expired session [%s] failed. [error: %s]",
+						session.sessionUUID.String(),
+						err.Error(),
+					),
+				)
+			}
+			log.Println(
+				fmt.Sprintf(
+					"FILESERVICE [WARNING]: Session [%s] with client [%s] expired and was aborted. [committed: %d]",
+					session.sessionUUID.String(),
+					string(session.remoteAddress()),
+					session.committedBytes(),
+				),
+			)
+			select {
+			case <-this.closing:
+				return
+			case this.SessionClosingEventChannel <- Event{
+				SessionUUID: session.sessionUUID.String(),
+				OK:          false,
+				Expired:     true,
+				Error:       ErrorSessionIsExpired,
+			}:
+			}
+		}
+	}
+}
+
+// Close stops reaping the expired sessions.
+func (this *Service) Close() {
+	this.closeOnce.Do(func() {
+		close(this.closing)
+	})
+}
+
 func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 	fileFrame := new(FileStreamingRequest)
-	err := proto.Unmarshal(context.Message, fileFrame)
+	err := context.Bind(fileFrame)
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
@@ -48,6 +186,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 				err.Error(),
 			),
 		)
+		this.replyFileFrame(context, nil, err)
 		this.FileFrameReceiveEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -64,6 +203,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 				err.Error(),
 			),
 		)
+		this.replyFileFrame(context, nil, err)
 		this.FileFrameReceiveEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -71,7 +211,22 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 		}
 		return
 	}
-	err = session.appendFileBytes(fileFrame.GetStreamingFrame())
+	if session.remoteAddress() != context.ClientRemoteAddress {
+		this.audit(
+			"client [%s] sent a frame to the session [%s] of the client [%s]",
+			string(context.ClientRemoteAddress),
+			fileFrame.GetSessionUuid(),
+			string(session.remoteAddress()),
+		)
+		this.replyFileFrame(context, nil, ErrorSessionOwnerMismatch)
+		this.FileFrameReceiveEventChannel <- Event{
+			Context: context,
+			OK:      false,
+			Error:   ErrorSessionOwnerMismatch,
+		}
+		return
+	}
+	_, err = session.appendFileFrame(fileFrame.Offset, fileFrame.Crc32C, fileFrame.GetStreamingFrame())
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
@@ -80,6 +235,10 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 				err.Error(),
 			),
 		)
+		if !IsRecoverableFrameError(err) {
+			this.abortSession(session)
+		}
+		this.replyFileFrame(context, session, err)
 		this.FileFrameReceiveEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -88,18 +247,14 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 		return
 	}
 	if fileFrame.GetLastFrame() {
-		err = session.writeToDisk()
+		// the session is claimed from the pool before it's finalized, so it
+		// can't be expired or aborted once its file is stored
+		err = this.poolSession.delete(uuidCode(fileFrame.GetSessionUuid()))
 		if err != nil {
-			log.Println(
-				fmt.Sprintf(
-					"FILESERVICE [ERROR]: Closing of the session. Receiving file frames from client [%s], with session [%s], completed failed. [%s]",
-					string(context.ClientRemoteAddress),
-					fileFrame.GetSessionUuid(),
-					err.Error(),
-				),
-			)
+			err = ErrorSessionIsClosed
+		} else {
+			_, err = this.finalizeSession(session)
 		}
-		err = this.poolSession.delete(uuidCode(fileFrame.GetSessionUuid()))
 		if err != nil {
 			log.Println(
 				fmt.Sprintf(
@@ -109,6 +264,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 					err.Error(),
 				),
 			)
+			this.replyFileFrame(context, session, err)
 			this.SessionClosingEventChannel <- Event{
 				Context: context,
 				OK:      false,
@@ -123,6 +279,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 				fileFrame.GetSessionUuid(),
 			),
 		)
+		this.replyFileFrame(context, session, nil)
 		this.SessionClosingEventChannel <- Event{
 			Context: context,
 			OK:      true,
@@ -137,6 +294,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 			fileFrame.GetSessionUuid(),
 		),
 	)
+	this.replyFileFrame(context, session, nil)
 	this.FileFrameReceiveEventChannel <- Event{
 		Context: context,
 		OK:      true,
@@ -146,7 +304,7 @@ func (this *Service) HandleReceivingFileFrames(context *streaming.Context) {
 
 func (this *Service) HandleOpenSession(context *streaming.Context) {
 	sessionStart := new(HandshakeRequest)
-	err := proto.Unmarshal(context.Message, sessionStart)
+	err := context.Bind(sessionStart)
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
@@ -155,6 +313,7 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 				err.Error(),
 			),
 		)
+		this.replyHandshake(context, nil, err)
 		this.SessionOpeningEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -162,24 +321,28 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 		}
 		return
 	}
-	if sessionStart.GetRemoteAddress() == "" {
-		log.Println(
-			fmt.Sprintf(
-				"FILESERVICE [ERROR]: An error occurred while opening a session with the client [%s]. [error: non exist address in context]",
-				"???",
-			),
+	if sessionStart.GetRemoteAddress() != "" && streaming.RemoteAddress(sessionStart.GetRemoteAddress()) != context.ClientRemoteAddress {
+		this.audit(
+			"client [%s] opened a session on behalf of [%s]",
+			string(context.ClientRemoteAddress),
+			sessionStart.GetRemoteAddress(),
 		)
+		this.replyHandshake(context, nil, ErrorSessionOwnerMismatch)
 		this.SessionOpeningEventChannel <- Event{
 			Context: context,
 			OK:      false,
-			Error:   err,
+			Error:   ErrorSessionOwnerMismatch,
 		}
 		return
 	}
-	websocketClient, err := this.websocketEngine.PoolClients.Get(
-		streaming.RemoteAddress(
-			sessionStart.GetRemoteAddress(),
-		),
+	if sessionStart.GetSessionUuid() != "" {
+		this.reattachSession(context, sessionStart)
+		return
+	}
+	session, err := newSession(
+		context.ClientRemoteAddress,
+		this.Storage,
+		sessionStart,
 	)
 	if err != nil {
 		log.Println(
@@ -189,6 +352,7 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 				err.Error(),
 			),
 		)
+		this.replyHandshake(context, nil, err)
 		this.SessionOpeningEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -196,13 +360,19 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 		}
 		return
 	}
-	if websocketClient == nil {
+	if context.Principal != nil {
+		session.principal = context.Principal.Subject
+	}
+	err = this.poolSession.push(session)
+	if err != nil {
 		log.Println(
 			fmt.Sprintf(
-				"FILESERVICE [ERROR]: An error occurred while opening a session with the client [%s]. [error: none exist client in pool]",
+				"FILESERVICE [ERROR]: An error occurred while opening a session with the client [%s]. [error: %s]",
 				string(context.ClientRemoteAddress),
+				err.Error(),
 			),
 		)
+		this.replyHandshake(context, nil, err)
 		this.SessionOpeningEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -210,22 +380,34 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 		}
 		return
 	}
-	session := newSession(
-		streaming.RemoteAddress(sessionStart.RemoteAddress),
-		strings.Join([]string{
-			this.RootPath,
-			this.StoragePath,
-		}, "/"),
+	log.Println(
+		fmt.Sprintf(
+			"FILESERVICE [OK]: Opening a session with the client [%s] completed successfully. [uuid: %s, file: %s]",
+			string(context.ClientRemoteAddress),
+			session.sessionUUID.String(),
+			session.fileName,
+		),
 	)
-	err = this.poolSession.push(session)
+	this.replyHandshake(context, session, nil)
+	this.SessionOpeningEventChannel <- Event{
+		Context: context,
+		OK:      true,
+		Error:   nil,
+	}
+}
+
+func (this *Service) reattachSession(context *streaming.Context, sessionStart *HandshakeRequest) {
+	session, err := this.poolSession.get(uuidCode(sessionStart.GetSessionUuid()))
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
-				"FILESERVICE [ERROR]: An error occurred while opening a session with the client [%s]. [error: %s]",
+				"FILESERVICE [ERROR]: An error occurred while reattaching the client [%s] to the session [%s]. [error: %s]",
 				string(context.ClientRemoteAddress),
+				sessionStart.GetSessionUuid(),
 				err.Error(),
 			),
 		)
+		this.replyHandshake(context, nil, err)
 		this.SessionOpeningEventChannel <- Event{
 			Context: context,
 			OK:      false,
@@ -233,16 +415,162 @@ func (this *Service) HandleOpenSession(context *streaming.Context) {
 		}
 		return
 	}
+	if !session.isResumeToken(sessionStart.GetResumeToken()) || !session.isPrincipal(context.Principal) {
+		this.audit(
+			"client [%s] tried to reattach to the session [%s] of the client [%s] without a valid resume token or principal",
+			string(context.ClientRemoteAddress),
+			sessionStart.GetSessionUuid(),
+			string(session.remoteAddress()),
+		)
+		this.replyHandshake(context, nil, ErrorSessionOwnerMismatch)
+		this.SessionOpeningEventChannel <- Event{
+			Context: context,
+			OK:      false,
+			Error:   ErrorSessionOwnerMismatch,
+		}
+		return
+	}
+	if !session.reattach(context.ClientRemoteAddress) {
+		this.audit(
+			"client [%s] tried to reattach to the session [%s] of the client [%s] which isn't paused",
+			string(context.ClientRemoteAddress),
+			sessionStart.GetSessionUuid(),
+			string(session.remoteAddress()),
+		)
+		this.replyHandshake(context, nil, ErrorSessionIsntPaused)
+		this.SessionOpeningEventChannel <- Event{
+			Context: context,
+			OK:      false,
+			Error:   ErrorSessionIsntPaused,
+		}
+		return
+	}
 	log.Println(
 		fmt.Sprintf(
-			"FILESERVICE [OK]: Opening a session with the client [%s] completed successfully. [uuid: %s]",
+			"FILESERVICE [OK]: Reattaching the client [%s] to the session [%s] completed successfully. [committed: %d]",
 			string(context.ClientRemoteAddress),
-			session.sessionUUID.String(),
+			sessionStart.GetSessionUuid(),
+			session.committedBytes(),
 		),
 	)
+	this.replyHandshake(context, session, nil)
 	this.SessionOpeningEventChannel <- Event{
 		Context: context,
 		OK:      true,
 		Error:   nil,
 	}
 }
+
+func (this *Service) HandleSessionStatus(context *streaming.Context, statusRequest *SessionStatusRequest) (*SessionStatusResponce, error) {
+	session, err := this.poolSession.get(uuidCode(statusRequest.GetSessionUuid()))
+	if err != nil {
+		return nil, err
+	}
+	if !session.isPrincipal(context.Principal) || (session.remoteAddress() != context.ClientRemoteAddress && !session.isResumeToken(statusRequest.GetResumeToken())) {
+		this.audit(
+			"client [%s] requested the status of the session [%s] of the client [%s]",
+			string(context.ClientRemoteAddress),
+			statusRequest.GetSessionUuid(),
+			string(session.remoteAddress()),
+		)
+		return nil, ErrorSessionOwnerMismatch
+	}
+	return &SessionStatusResponce{
+		SessionUuid:    session.sessionUUID.String(),
+		FileName:       session.fileName,
+		FileSize:       session.fileSize,
+		CommittedBytes: session.committedBytes(),
+		Paused:         session.isPaused(),
+	}, nil
+}
+
+// finalizeSession commits the uploaded bytes and records the file in the
+// catalog; the stored file is removed again if the record can't be written.
+func (this *Service) finalizeSession(session *session) (FileRecord, error) {
+	if err := session.commit(); err != nil {
+		return FileRecord{}, err
+	}
+	info, err := this.Storage.Stat(session.storageName())
+	if err != nil {
+		return FileRecord{}, err
+	}
+	record := FileRecord{
+		ID:              session.sessionUUID.String(),
+		Name:            session.fileName,
+		StorageName:     session.storageName(),
+		Size:            info.Size,
+		ContentType:     session.contentType,
+		Checksum:        session.checksum(),
+		UploaderAddress: string(session.remoteAddress()),
+		Owner:           session.principal,
+		SessionUUID:     session.sessionUUID.String(),
+		CreatedAt:       session.createdAt,
+		UploadedAt:      time.Now(),
+	}
+	if err := this.Catalog.Put(record); err != nil {
+		this.Storage.Delete(session.storageName())
+		return FileRecord{}, err
+	}
+	this.publishFileEvent(FileEvent_UPLOADED, record)
+	return record, nil
+}
+
+func (this *Service) audit(format string, arguments ...interface{}) {
+	log.Println("FILESERVICE [AUDIT]: " + fmt.Sprintf(format, arguments...))
+}
+
+func (this *Service) abortSession(session *session) {
+	if err := session.abort(); err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"FILESERVICE [ERROR]: Aborting of the session [%s] failed. [error: %s]",
+				session.sessionUUID.String(),
+				err.Error(),
+			),
+		)
+	}
+	this.poolSession.delete(uuidCode(session.sessionUUID.String()))
+}
+
+func (this *Service) replyHandshake(context *streaming.Context, session *session, err error) {
+	if err != nil {
+		err = context.ReplyError(err)
+	} else {
+		err = context.Reply(&HandshakeResponce{
+			SessionUuid:    session.sessionUUID.String(),
+			FileName:       session.fileName,
+			CommittedBytes: session.committedBytes(),
+			ResumeToken:    session.resumeToken,
+		})
+	}
+	if err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"FILESERVICE [ERROR]: Sending the handshake responce to the client [%s] failed. [error: %s]",
+				string(context.ClientRemoteAddress),
+				err.Error(),
+			),
+		)
+	}
+}
+
+func (this *Service) replyFileFrame(context *streaming.Context, session *session, err error) {
+	responce := &FileStreamingResponce{
+		Ok: err == nil,
+	}
+	if session != nil {
+		responce.CommittedBytes = session.committedBytes()
+	}
+	if err != nil {
+		responce.Error = err.Error()
+	}
+	if err := this.websocketEngine.SendResponceClient(context, responce, err); err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"FILESERVICE [ERROR]: Sending the file frame responce to the client [%s] failed. [error: %s]",
+				string(context.ClientRemoteAddress),
+				err.Error(),
+			),
+		)
+	}
+}
@@ -1,50 +1,263 @@
 package fileservice
 
 import (
-	"bufio"
 	"bytes"
-	"os"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"path"
 	"protoservice/src/streaming"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
 	"github.com/google/uuid"
 )
 
+const maxFileNameLength = 255
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
 type session struct {
-	storagePath         string
+	mx                  *sync.Mutex
+	storage             Storage
 	remoteClientAddress streaming.RemoteAddress
+	principal           string
 	sessionUUID         uuid.UUID
-	fileBuffer          *bytes.Buffer
+	resumeToken         string
+	fileName            string
+	fileSize            uint64
+	contentType         string
+	expectedSHA256      []byte
+	digest              hash.Hash
+	receivedBytes       uint64
+	writer              StorageWriter
+	createdAt           time.Time
+	lastActivity        time.Time
+	resumable           bool
+	paused              bool
+	closed              bool
 }
 
-func newSession(remoteClientAddress streaming.RemoteAddress, storagePath string) *session {
+func newSession(remoteClientAddress streaming.RemoteAddress, storage Storage, handshake *HandshakeRequest) (*session, error) {
+	fileName, err := sanitizeFileName(handshake.GetFileName())
+	if err != nil {
+		return nil, err
+	}
+	if len(handshake.GetSha256()) != 0 && len(handshake.GetSha256()) != sha256.Size {
+		return nil, ErrorChecksumIsntValid
+	}
+	resumeToken := make([]byte, 32)
+	if _, err := rand.Read(resumeToken); err != nil {
+		return nil, err
+	}
 	this := new(session)
+	this.mx = new(sync.Mutex)
+	this.resumeToken = hex.EncodeToString(resumeToken)
+	this.storage = storage
 	this.remoteClientAddress = remoteClientAddress
 	this.sessionUUID = uuid.New()
-	this.fileBuffer = bytes.NewBuffer(nil)
-	return this
+	this.fileName = fileName
+	this.fileSize = handshake.GetFileSize()
+	this.contentType = handshake.GetContentType()
+	this.expectedSHA256 = handshake.GetSha256()
+	this.resumable = handshake.GetResumable()
+	this.digest = sha256.New()
+	this.createdAt = time.Now()
+	this.lastActivity = this.createdAt
+	return this, nil
 }
 
-func (this *session) appendFileBytes(fileBytes []byte) error {
-	_, err := this.fileBuffer.Write(fileBytes)
+// sanitizeFileName drops every directory component of the client supplied
+// name, so an upload can never leave its own session directory, and
+// rejects the names reserved for the temporary files of LocalStorage.
+func sanitizeFileName(fileName string) (string, error) {
+	fileName = path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "." || fileName == ".." || fileName == "/" || fileName == "" {
+		return "", ErrorFileNameIsntValid
+	}
+	if len(fileName) > maxFileNameLength {
+		return "", ErrorFileNameIsntValid
+	}
+	if strings.IndexFunc(fileName, unicode.IsControl) != -1 || isTemporaryFile(fileName) {
+		return "", ErrorFileNameIsntValid
+	}
+	return fileName, nil
+}
+
+func (this *session) storageName() string {
+	return path.Join(this.sessionUUID.String(), this.fileName)
+}
+
+func (this *session) openWriter() error {
+	if this.writer != nil {
+		return nil
+	}
+	writer, err := this.storage.Create(this.storageName())
 	if err != nil {
 		return err
 	}
+	this.writer = writer
 	return nil
 }
 
-func (this *session) writeToDisk() error {
-	file, err := os.Create(this.storagePath)
-	if err != nil {
-		return err
+// appendFileFrame writes a frame starting at offset. Frames that were
+// already committed (e.g. resent after a reconnect) are acknowledged without
+// being written again; a frame leaving a gap after the committed bytes is
+// rejected. A frame without an offset is appended to the end.
+func (this *session) appendFileFrame(offset *uint64, checksum *uint32, fileBytes []byte) (uint64, error) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if this.closed {
+		return this.receivedBytes, ErrorSessionIsClosed
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
-	_, err = writer.Write(this.fileBuffer.Bytes())
-	if err != nil {
+	if this.paused {
+		return this.receivedBytes, ErrorSessionIsPaused
+	}
+	this.lastActivity = time.Now()
+	if checksum != nil && crc32.Checksum(fileBytes, crc32cTable) != *checksum {
+		return this.receivedBytes, ErrorFrameChecksumMismatch
+	}
+	if offset != nil {
+		if *offset > this.receivedBytes {
+			return this.receivedBytes, ErrorFrameOffsetMismatch
+		}
+		alreadyReceived := this.receivedBytes - *offset
+		if alreadyReceived >= uint64(len(fileBytes)) {
+			return this.receivedBytes, nil
+		}
+		fileBytes = fileBytes[alreadyReceived:]
+	}
+	err := this.appendFileBytes(fileBytes)
+	return this.receivedBytes, err
+}
+
+func (this *session) appendFileBytes(fileBytes []byte) error {
+	if this.fileSize != 0 && this.receivedBytes+uint64(len(fileBytes)) > this.fileSize {
+		return ErrorFileSizeMismatch
+	}
+	if err := this.openWriter(); err != nil {
 		return err
 	}
-	err = writer.Flush()
+	n, err := this.writer.Write(fileBytes)
+	this.digest.Write(fileBytes[:n])
+	this.receivedBytes += uint64(n)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (this *session) committedBytes() uint64 {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	return this.receivedBytes
+}
+
+func (this *session) checksum() string {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	return hex.EncodeToString(this.digest.Sum(nil))
+}
+
+func (this *session) remoteAddress() streaming.RemoteAddress {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	return this.remoteClientAddress
+}
+
+// reattach moves a paused resumable session to a new connection, it reports
+// false for any other session.
+func (this *session) reattach(remoteClientAddress streaming.RemoteAddress) bool {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if !this.resumable || !this.paused {
+		return false
+	}
+	this.remoteClientAddress = remoteClientAddress
+	this.paused = false
+	this.lastActivity = time.Now()
+	return true
+}
+
+func (this *session) isResumeToken(resumeToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(resumeToken), []byte(this.resumeToken)) == 1
+}
+
+// isPrincipal reports whether principal may act on the session; a session
+// opened by an anonymous client isn't bound to any principal.
+func (this *session) isPrincipal(principal *streaming.Principal) bool {
+	if this.principal == "" {
+		return true
+	}
+	return principal != nil && principal.Subject == this.principal
+}
+
+// pause keeps a resumable session until its client reattaches from a new
+// connection (or the session expires).
+func (this *session) pause() {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.paused = true
+}
+
+func (this *session) isPaused() bool {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	return this.paused
+}
+
+func (this *session) isExpired(idleTimeout, maxLifetime time.Duration, now time.Time) bool {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if idleTimeout != 0 && now.Sub(this.lastActivity) > idleTimeout {
+		return true
+	}
+	if maxLifetime != 0 && now.Sub(this.createdAt) > maxLifetime {
+		return true
+	}
+	return false
+}
+
+func (this *session) commit() error {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if this.closed {
+		return ErrorSessionIsClosed
+	}
+	this.closed = true
+	if this.fileSize != 0 && this.receivedBytes != this.fileSize {
+		this.abortWriter()
+		return ErrorFileSizeMismatch
+	}
+	if len(this.expectedSHA256) != 0 && !bytes.Equal(this.digest.Sum(nil), this.expectedSHA256) {
+		this.abortWriter()
+		return ErrorFileChecksumMismatch
+	}
+	if err := this.openWriter(); err != nil {
+		return err
+	}
+	err := this.writer.Commit()
+	this.writer = nil
+	return err
+}
+
+func (this *session) abort() error {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.closed = true
+	return this.abortWriter()
+}
+
+func (this *session) abortWriter() error {
+	if this.writer == nil {
+		return nil
+	}
+	err := this.writer.Abort()
+	this.writer = nil
+	return err
+}
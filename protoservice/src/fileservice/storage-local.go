@@ -0,0 +1,195 @@
+package fileservice
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const temporaryFileSuffix = ".part"
+
+type LocalStorage struct {
+	directory string
+}
+
+func NewLocalStorage(rootPath, storagePath string) *LocalStorage {
+	this := new(LocalStorage)
+	this.directory = filepath.Join(rootPath, storagePath)
+	return this
+}
+
+func (this *LocalStorage) path(name string) (string, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return "", err
+	}
+	if isTemporaryFile(path.Base(cleaned)) {
+		return "", ErrorFileNameIsntValid
+	}
+	return filepath.Join(this.directory, filepath.FromSlash(cleaned)), nil
+}
+
+func (this *LocalStorage) Create(name string) (StorageWriter, error) {
+	filePath, err := this.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*"+temporaryFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	return &localStorageWriter{
+		file:     file,
+		filePath: filePath,
+	}, nil
+}
+
+func (this *LocalStorage) Open(name string) (io.ReadSeekCloser, error) {
+	filePath, err := this.path(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrorFileIsntExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (this *LocalStorage) Stat(name string) (FileInfo, error) {
+	filePath, err := this.path(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return FileInfo{}, ErrorFileIsntExist
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Name:    name,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+func (this *LocalStorage) Delete(name string) error {
+	filePath, err := this.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filePath)
+	if os.IsNotExist(err) {
+		return ErrorFileIsntExist
+	}
+	if err != nil {
+		return err
+	}
+	for directory := filepath.Dir(filePath); directory != this.directory; directory = filepath.Dir(directory) {
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (this *LocalStorage) List(prefix string) ([]FileInfo, error) {
+	files := make([]FileInfo, 0)
+	err := filepath.Walk(this.directory, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || isTemporaryFile(info.Name()) {
+			return nil
+		}
+		name, err := filepath.Rel(this.directory, filePath)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		files = append(files, FileInfo{
+			Name:    name,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// isTemporaryFile matches the names CreateTemp gives to uploads that are
+// not committed yet; such names are reserved, so List can hide them.
+func isTemporaryFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, temporaryFileSuffix)
+}
+
+type localStorageWriter struct {
+	file     *os.File
+	filePath string
+}
+
+func (this *localStorageWriter) Write(bytes []byte) (int, error) {
+	if this.file == nil {
+		return 0, ErrorWriterIsCompleted
+	}
+	return this.file.Write(bytes)
+}
+
+func (this *localStorageWriter) Commit() error {
+	if this.file == nil {
+		return ErrorWriterIsCompleted
+	}
+	if err := this.file.Sync(); err != nil {
+		this.Abort()
+		return err
+	}
+	if err := this.file.Close(); err != nil {
+		os.Remove(this.file.Name())
+		this.file = nil
+		return err
+	}
+	temporaryFilePath := this.file.Name()
+	this.file = nil
+	if err := os.Rename(temporaryFilePath, this.filePath); err != nil {
+		os.Remove(temporaryFilePath)
+		return err
+	}
+	if directory, err := os.Open(filepath.Dir(this.filePath)); err == nil {
+		directory.Sync()
+		directory.Close()
+	}
+	return nil
+}
+
+func (this *localStorageWriter) Abort() error {
+	if this.file == nil {
+		return nil
+	}
+	this.file.Close()
+	err := os.Remove(this.file.Name())
+	this.file = nil
+	return err
+}
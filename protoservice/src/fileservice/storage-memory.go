@@ -0,0 +1,151 @@
+package fileservice
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+type MemoryStorage struct {
+	mx    *sync.RWMutex
+	files map[string]*memoryFile
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	this := new(MemoryStorage)
+	this.mx = new(sync.RWMutex)
+	this.files = make(map[string]*memoryFile)
+	return this
+}
+
+func (this *MemoryStorage) Create(name string) (StorageWriter, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryStorageWriter{
+		storage: this,
+		name:    cleaned,
+		buffer:  bytes.NewBuffer(nil),
+	}, nil
+}
+
+func (this *MemoryStorage) get(name string) (*memoryFile, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return nil, err
+	}
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	if file, exist := this.files[cleaned]; exist {
+		return file, nil
+	}
+	return nil, ErrorFileIsntExist
+}
+
+func (this *MemoryStorage) Open(name string) (io.ReadSeekCloser, error) {
+	file, err := this.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryFileReader{
+		Reader: bytes.NewReader(file.data),
+	}, nil
+}
+
+func (this *MemoryStorage) Stat(name string) (FileInfo, error) {
+	file, err := this.get(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Name:    name,
+		Size:    int64(len(file.data)),
+		ModTime: file.modTime,
+	}, nil
+}
+
+func (this *MemoryStorage) Delete(name string) error {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return err
+	}
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if _, exist := this.files[cleaned]; !exist {
+		return ErrorFileIsntExist
+	}
+	delete(this.files, cleaned)
+	return nil
+}
+
+func (this *MemoryStorage) List(prefix string) ([]FileInfo, error) {
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	files := make([]FileInfo, 0)
+	for name, file := range this.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		files = append(files, FileInfo{
+			Name:    name,
+			Size:    int64(len(file.data)),
+			ModTime: file.modTime,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+type memoryFileReader struct {
+	*bytes.Reader
+}
+
+func (this *memoryFileReader) Close() error {
+	return nil
+}
+
+type memoryStorageWriter struct {
+	storage   *MemoryStorage
+	name      string
+	buffer    *bytes.Buffer
+	completed bool
+}
+
+func (this *memoryStorageWriter) Write(bytes []byte) (int, error) {
+	if this.completed {
+		return 0, ErrorWriterIsCompleted
+	}
+	return this.buffer.Write(bytes)
+}
+
+func (this *memoryStorageWriter) Commit() error {
+	if this.completed {
+		return ErrorWriterIsCompleted
+	}
+	this.completed = true
+	this.storage.mx.Lock()
+	defer this.storage.mx.Unlock()
+	this.storage.files[this.name] = &memoryFile{
+		data:    this.buffer.Bytes(),
+		modTime: time.Now(),
+	}
+	this.buffer = nil
+	return nil
+}
+
+func (this *memoryStorageWriter) Abort() error {
+	this.completed = true
+	this.buffer = nil
+	return nil
+}
@@ -0,0 +1,175 @@
+package fileservice
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const s3PartSize = 16 * 1024 * 1024
+
+// S3Storage keeps files as objects of a single bucket of any S3-compatible
+// service (AWS S3, MinIO, ...).
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Storage(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*S3Storage, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	exist, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		err = client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+	this := new(S3Storage)
+	this.client = client
+	this.bucket = bucket
+	return this, nil
+}
+
+func (this *S3Storage) Create(name string) (StorageWriter, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := this.client.PutObject(
+			context.Background(),
+			this.bucket,
+			cleaned,
+			reader,
+			-1,
+			minio.PutObjectOptions{
+				PartSize: s3PartSize,
+			},
+		)
+		reader.CloseWithError(err)
+		done <- err
+	}()
+	return &s3StorageWriter{
+		writer: writer,
+		done:   done,
+	}, nil
+}
+
+func (this *S3Storage) Open(name string) (io.ReadSeekCloser, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return nil, err
+	}
+	object, err := this.client.GetObject(context.Background(), this.bucket, cleaned, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, this.convertError(err)
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, this.convertError(err)
+	}
+	return object, nil
+}
+
+func (this *S3Storage) Stat(name string) (FileInfo, error) {
+	cleaned, err := cleanStorageName(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := this.client.StatObject(context.Background(), this.bucket, cleaned, minio.StatObjectOptions{})
+	if err != nil {
+		return FileInfo{}, this.convertError(err)
+	}
+	return FileInfo{
+		Name:    name,
+		Size:    info.Size,
+		ModTime: info.LastModified,
+	}, nil
+}
+
+func (this *S3Storage) Delete(name string) error {
+	if _, err := this.Stat(name); err != nil {
+		return err
+	}
+	cleaned, _ := cleanStorageName(name)
+	err := this.client.RemoveObject(context.Background(), this.bucket, cleaned, minio.RemoveObjectOptions{})
+	if err != nil {
+		return this.convertError(err)
+	}
+	return nil
+}
+
+func (this *S3Storage) List(prefix string) ([]FileInfo, error) {
+	files := make([]FileInfo, 0)
+	objects := this.client.ListObjects(context.Background(), this.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		files = append(files, FileInfo{
+			Name:    object.Key,
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+func (this *S3Storage) convertError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrorFileIsntExist
+	}
+	return err
+}
+
+type s3StorageWriter struct {
+	writer    *io.PipeWriter
+	done      chan error
+	completed bool
+}
+
+func (this *s3StorageWriter) Write(bytes []byte) (int, error) {
+	if this.completed {
+		return 0, ErrorWriterIsCompleted
+	}
+	return this.writer.Write(bytes)
+}
+
+func (this *s3StorageWriter) Commit() error {
+	if this.completed {
+		return ErrorWriterIsCompleted
+	}
+	this.completed = true
+	this.writer.Close()
+	return <-this.done
+}
+
+func (this *s3StorageWriter) Abort() error {
+	if this.completed {
+		return nil
+	}
+	this.completed = true
+	this.writer.CloseWithError(ErrorUploadIsAborted)
+	<-this.done
+	return nil
+}
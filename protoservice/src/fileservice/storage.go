@@ -0,0 +1,50 @@
+package fileservice
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// StorageWriter receives the bytes of a single file. Nothing is visible in
+// the storage until Commit succeeds; Abort discards everything written.
+type StorageWriter interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+type Storage interface {
+	Create(name string) (StorageWriter, error)
+	Open(name string) (io.ReadSeekCloser, error)
+	Stat(name string) (FileInfo, error)
+	Delete(name string) error
+	List(prefix string) ([]FileInfo, error)
+}
+
+var (
+	ErrorFileIsntExist     = errors.New("Error: file isn't exist")
+	ErrorFileNameIsntValid = errors.New("Error: file name isn't valid")
+	ErrorWriterIsCompleted = errors.New("Error: storage writer is already committed or aborted")
+	ErrorUploadIsAborted   = errors.New("Error: upload is aborted")
+	ErrorFileSizeMismatch  = errors.New("Error: received bytes don't match the declared file size")
+)
+
+func cleanStorageName(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") {
+		return "", ErrorFileNameIsntValid
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrorFileNameIsntValid
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,57 @@
+package fileservice
+
+import "errors"
+
+var (
+	ErrorSessionUuidIsEmpty = errors.New("Error: session uuid is empty")
+	ErrorSizeRangeIsntValid = errors.New("Error: size range isn't valid")
+)
+
+func (this *HandshakeRequest) Validate() error {
+	if this.GetSessionUuid() == "" && this.GetFileName() == "" {
+		return ErrorFileNameIsntValid
+	}
+	return nil
+}
+
+func (this *FileStreamingRequest) Validate() error {
+	if this.GetSessionUuid() == "" {
+		return ErrorSessionUuidIsEmpty
+	}
+	return nil
+}
+
+func (this *SessionStatusRequest) Validate() error {
+	if this.GetSessionUuid() == "" {
+		return ErrorSessionUuidIsEmpty
+	}
+	return nil
+}
+
+func (this *FileDownloadRequest) Validate() error {
+	if this.GetFileId() == "" {
+		return ErrorFileIdIsntValid
+	}
+	return nil
+}
+
+func (this *FileInfoRequest) Validate() error {
+	if this.GetFileId() == "" {
+		return ErrorFileIdIsntValid
+	}
+	return nil
+}
+
+func (this *FileDeleteRequest) Validate() error {
+	if this.GetFileId() == "" {
+		return ErrorFileIdIsntValid
+	}
+	return nil
+}
+
+func (this *FileListRequest) Validate() error {
+	if this.GetMaxSize() != 0 && this.GetMinSize() > this.GetMaxSize() {
+		return ErrorSizeRangeIsntValid
+	}
+	return nil
+}
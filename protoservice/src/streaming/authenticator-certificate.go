@@ -0,0 +1,32 @@
+package streaming
+
+import "net/http"
+
+type certificateAuthenticator struct{}
+
+// NewCertificateAuthenticator authenticates clients by the certificate
+// they presented during the TLS handshake. The server's tls.Config must
+// verify client certificates (ClientAuth VerifyClientCertIfGiven or
+// RequireAndVerifyClientCert); the subject common name becomes the
+// principal and the organizational units become its roles.
+func NewCertificateAuthenticator() Authenticator {
+	return new(certificateAuthenticator)
+}
+
+func (this *certificateAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, ErrorCredentialsAreMissing
+	}
+	if len(r.TLS.VerifiedChains) == 0 {
+		return nil, ErrorCredentialsArentValid
+	}
+	certificate := r.TLS.VerifiedChains[0][0]
+	if certificate.Subject.CommonName == "" {
+		return nil, ErrorCredentialsArentValid
+	}
+	return &Principal{
+		Subject: certificate.Subject.CommonName,
+		Method:  "mtls",
+		Roles:   append([]string(nil), certificate.Subject.OrganizationalUnit...),
+	}, nil
+}
@@ -0,0 +1,151 @@
+package streaming
+
+import (
+	"crypto"
+	"crypto/hmac"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTAuthenticator verifies HMAC-signed JSON web tokens (HS256, HS384 and
+// HS512) with the local keys. A token header naming a "kid" is checked
+// with that key only, otherwise every key is tried.
+type JWTAuthenticator struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	keys     map[string][]byte
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Roles     []string        `json:"roles"`
+	Scope     string          `json:"scope"`
+}
+
+var jwtAlgorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+}
+
+func NewJWTAuthenticator(keys map[string][]byte) *JWTAuthenticator {
+	this := new(JWTAuthenticator)
+	this.keys = keys
+	return this
+}
+
+func (this *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return this.Verify(token, time.Now())
+}
+
+func (this *JWTAuthenticator) Verify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrorCredentialsArentValid
+	}
+	header := new(jwtHeader)
+	if err := decodeJWTPart(parts[0], header); err != nil {
+		return nil, ErrorCredentialsArentValid
+	}
+	hash, exist := jwtAlgorithms[header.Algorithm]
+	if !exist {
+		return nil, ErrorCredentialsArentValid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrorCredentialsArentValid
+	}
+	if !this.verifySignature(hash, header.KeyID, parts[0]+"."+parts[1], signature) {
+		return nil, ErrorCredentialsArentValid
+	}
+	claims := new(jwtClaims)
+	if err := decodeJWTPart(parts[1], claims); err != nil {
+		return nil, ErrorCredentialsArentValid
+	}
+	if claims.ExpiresAt != nil && !now.Before(time.Unix(*claims.ExpiresAt, 0).Add(this.Leeway)) {
+		return nil, ErrorCredentialsAreExpired
+	}
+	if claims.NotBefore != nil && now.Add(this.Leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, ErrorCredentialsArentValid
+	}
+	if this.Issuer != "" && claims.Issuer != this.Issuer {
+		return nil, ErrorCredentialsArentValid
+	}
+	if this.Audience != "" && !claims.hasAudience(this.Audience) {
+		return nil, ErrorCredentialsArentValid
+	}
+	if claims.Subject == "" {
+		return nil, ErrorCredentialsArentValid
+	}
+	return &Principal{
+		Subject: claims.Subject,
+		Method:  "jwt",
+		Roles:   claims.Roles,
+		Scopes:  strings.Fields(claims.Scope),
+	}, nil
+}
+
+func (this *JWTAuthenticator) verifySignature(hash crypto.Hash, kid, signingInput string, signature []byte) bool {
+	verify := func(key []byte) bool {
+		mac := hmac.New(hash.New, key)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	if kid != "" {
+		key, exist := this.keys[kid]
+		return exist && verify(key)
+	}
+	for _, key := range this.keys {
+		if verify(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *jwtClaims) hasAudience(audience string) bool {
+	if len(this.Audience) == 0 {
+		return false
+	}
+	single := ""
+	if err := json.Unmarshal(this.Audience, &single); err == nil {
+		return single == audience
+	}
+	list := make([]string, 0)
+	if err := json.Unmarshal(this.Audience, &list); err != nil {
+		return false
+	}
+	for _, value := range list {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, value interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, value)
+}
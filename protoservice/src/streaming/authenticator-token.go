@@ -0,0 +1,40 @@
+package streaming
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+type staticTokenAuthenticator struct {
+	tokens map[[sha256.Size]byte]Principal
+}
+
+// NewStaticTokenAuthenticator accepts the bearer tokens of the list, each
+// one mapped to the principal it authenticates.
+func NewStaticTokenAuthenticator(tokens map[string]Principal) Authenticator {
+	this := new(staticTokenAuthenticator)
+	this.tokens = make(map[[sha256.Size]byte]Principal, len(tokens))
+	for token, principal := range tokens {
+		this.tokens[sha256.Sum256([]byte(token))] = principal
+	}
+	return this
+}
+
+func (this *staticTokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	// tokens are compared by digest so the lookup time doesn't depend on
+	// how many leading bytes of a guess are right
+	digest := sha256.Sum256([]byte(token))
+	for key, principal := range this.tokens {
+		if subtle.ConstantTimeCompare(key[:], digest[:]) == 1 {
+			principal := principal
+			principal.Method = "token"
+			return &principal, nil
+		}
+	}
+	return nil, ErrorCredentialsArentValid
+}
@@ -0,0 +1,93 @@
+package streaming
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Principal is the identity a client proved while its websocket was
+// being opened.
+type Principal struct {
+	Subject string
+	Method  string
+	Roles   []string
+	Scopes  []string
+}
+
+// Authenticator checks the credentials of an upgrade request before the
+// protocol is switched.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+var (
+	ErrorCredentialsAreMissing    = errors.New("Error: credentials are missing")
+	ErrorCredentialsArentValid    = errors.New("Error: credentials aren't valid")
+	ErrorCredentialsAreExpired    = errors.New("Error: credentials are expired")
+	ErrorClientIsntAuthenticated  = errors.New("Error: client isn't authenticated")
+	ErrorAuthenticatorsArentExist = errors.New("Error: authenticators aren't exist")
+)
+
+func (this *Principal) HasRole(role string) bool {
+	if this == nil {
+		return false
+	}
+	for _, value := range this.Roles {
+		if value == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Principal) HasScope(scope string) bool {
+	if this == nil {
+		return false
+	}
+	for _, value := range this.Scopes {
+		if value == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrorCredentialsAreMissing
+	}
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", ErrorCredentialsArentValid
+	}
+	return strings.TrimSpace(header[len(prefix):]), nil
+}
+
+type chainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewChainAuthenticator tries the authenticators in order. A request
+// without credentials for one of them falls through to the next one,
+// while invalid credentials are rejected at once.
+func NewChainAuthenticator(authenticators ...Authenticator) Authenticator {
+	this := new(chainAuthenticator)
+	this.authenticators = authenticators
+	return this
+}
+
+func (this *chainAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if len(this.authenticators) == 0 {
+		return nil, ErrorAuthenticatorsArentExist
+	}
+	for _, authenticator := range this.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if err == ErrorCredentialsAreMissing {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrorCredentialsAreMissing
+}
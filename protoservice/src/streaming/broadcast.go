@@ -0,0 +1,114 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// BroadcastURI is the URI of the Responce sent by the broadcasts, it has
+// no request ID and names the group when sent to one.
+const BroadcastURI URI = "/broadcast"
+
+var (
+	ErrorSendQueueIsFull = errors.New("Error: send queue is full")
+	ErrorGroupIsEmpty    = errors.New("Error: group name is empty")
+)
+
+// ClientInfo describes a connected client to a broadcast predicate.
+type ClientInfo struct {
+	RemoteAddress RemoteAddress
+	Principal     *Principal
+}
+
+// Broadcast sends message to every connected client and returns the number
+// of clients it was queued for. Clients with a full send queue miss it.
+func (this *Engine) Broadcast(message proto.Message) (int, error) {
+	return this.BroadcastFunc(func(ClientInfo) bool {
+		return true
+	}, message)
+}
+
+// BroadcastFunc sends message to the connected clients matching predicate.
+func (this *Engine) BroadcastFunc(predicate func(client ClientInfo) bool, message proto.Message) (int, error) {
+	clients := make([]*client, 0)
+	for tuple := range this.PoolClients.Iterate() {
+		if predicate(ClientInfo{RemoteAddress: tuple.Address, Principal: tuple.Client.principal}) {
+			clients = append(clients, tuple.Client)
+		}
+	}
+	return this.fanOut(clients, &Responce{
+		Uri: string(BroadcastURI),
+	}, message)
+}
+
+// BroadcastGroup sends message to the clients added to group.
+func (this *Engine) BroadcastGroup(group string, message proto.Message) (int, error) {
+	clients := make([]*client, 0)
+	for _, clientRemoteAddress := range this.groups.getMembers(group) {
+		client, err := this.PoolClients.Get(clientRemoteAddress)
+		if err != nil {
+			this.groups.removeAll(clientRemoteAddress)
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return this.fanOut(clients, &Responce{
+		Uri:   string(BroadcastURI),
+		Group: group,
+	}, message)
+}
+
+// AddToGroup adds a connected client to group, the client leaves all of
+// its groups when it disconnects.
+func (this *Engine) AddToGroup(group string, clientRemoteAddress RemoteAddress) error {
+	if group == "" {
+		return ErrorGroupIsEmpty
+	}
+	if _, err := this.PoolClients.Get(clientRemoteAddress); err != nil {
+		return err
+	}
+	this.groups.add(clientRemoteAddress, group)
+	return nil
+}
+
+func (this *Engine) RemoveFromGroup(group string, clientRemoteAddress RemoteAddress) {
+	this.groups.remove(clientRemoteAddress, group)
+}
+
+// fanOut marshals message into responce once and queues it for clients
+// without waiting for any of them.
+func (this *Engine) fanOut(clients []*client, responce *Responce, message proto.Message) (int, error) {
+	frame, err := proto.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+	responce.Frame = frame
+	readyMessage, err := proto.Marshal(responce)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, client := range clients {
+		err := client.trySendMessage(readyMessage)
+		if err == ErrorSendQueueIsFull {
+			this.metrics.messageDropped()
+			log.Println(
+				fmt.Sprintf(
+					"STREAMING [WARNING]: Message [%s] for the client [%s] is dropped. [error: %s]",
+					responce.GetUri(),
+					string(client.websocketRemoteAddress),
+					err.Error(),
+				),
+			)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		delivered++
+	}
+	return delivered, nil
+}
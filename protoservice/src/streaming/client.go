@@ -5,28 +5,42 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const writeTimeout = 10 * time.Second
+
+// client writes its messages from its own goroutine fed by a bounded send
+// queue: a slow connection fills only its queue, and messages queued
+// before the connection is closed are still written.
 type client struct {
 	httpRemoteAddress      RemoteAddress
 	websocketRemoteAddress RemoteAddress
 	connection             *websocket.Conn
+	principal              *Principal
 	mx                     *sync.Mutex
-	callback               func(clientRemoteAddress RemoteAddress, message []byte)
+	callback               func(client *client, message []byte)
+	disconnectCallback     func(clientRemoteAddress RemoteAddress)
 	connectionIsClosed     bool
+	sendQueue              chan []byte
+	closing                chan struct{}
+	senders                *sync.WaitGroup
+	tasks                  *clientTasks
 }
 
-func newClient(w http.ResponseWriter, r *http.Request, callback func(clientRemoteAddress RemoteAddress, message []byte)) (*client, error) {
+func newClient(w http.ResponseWriter, r *http.Request, principal *Principal, sendQueueSize int, callback func(client *client, message []byte), disconnectCallback func(clientRemoteAddress RemoteAddress)) (*client, error) {
 	this := new(client)
+	this.mx = new(sync.Mutex)
+	this.principal = principal
 	upgrader := &websocket.Upgrader{}
 	connection, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
 				"STREAMING [ERROR]: Protocol switching, for client [%s], happened with an error: [%s]",
-				this.websocketRemoteAddress,
+				r.RemoteAddr,
 				err.Error(),
 			),
 		)
@@ -35,6 +49,11 @@ func newClient(w http.ResponseWriter, r *http.Request, callback func(clientRemot
 	this.connection = connection
 	this.websocketRemoteAddress = RemoteAddress(connection.RemoteAddr().String())
 	this.callback = callback
+	this.disconnectCallback = disconnectCallback
+	this.sendQueue = make(chan []byte, sendQueueSize)
+	this.closing = make(chan struct{})
+	this.senders = new(sync.WaitGroup)
+	go this.writeMessages()
 	log.Println(
 		fmt.Sprintf(
 			"STREAMING [OK]: Protocol switch, for client [%s], succeeded",
@@ -44,12 +63,76 @@ func newClient(w http.ResponseWriter, r *http.Request, callback func(clientRemot
 	return this, err
 }
 
+// sendMessage queues message, waiting for room in the queue.
 func (this *client) sendMessage(message []byte) error {
-	if !this.connectionIsClosed {
+	if !this.startSending() {
+		return ErrorConnectionIsClosed
+	}
+	defer this.senders.Done()
+	select {
+	case this.sendQueue <- message:
 		return nil
+	case <-this.closing:
+		return ErrorConnectionIsClosed
+	}
+}
+
+// trySendMessage queues message unless the queue is full.
+func (this *client) trySendMessage(message []byte) error {
+	if !this.startSending() {
+		return ErrorConnectionIsClosed
 	}
+	defer this.senders.Done()
+	select {
+	case this.sendQueue <- message:
+		return nil
+	case <-this.closing:
+		return ErrorConnectionIsClosed
+	default:
+		return ErrorSendQueueIsFull
+	}
+}
+
+// startSending registers a sender unless the connection is closed, the
+// writer waits for the registered senders before its last drain so that
+// every accepted message is written.
+func (this *client) startSending() bool {
 	this.mx.Lock()
 	defer this.mx.Unlock()
+	if this.connectionIsClosed {
+		return false
+	}
+	this.senders.Add(1)
+	return true
+}
+
+func (this *client) writeMessages() {
+	defer this.connection.Close()
+	for {
+		select {
+		case message := <-this.sendQueue:
+			if err := this.writeMessage(message); err != nil {
+				this.closeConnection()
+				return
+			}
+		case <-this.closing:
+			this.senders.Wait()
+			for {
+				select {
+				case message := <-this.sendQueue:
+					if err := this.writeMessage(message); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (this *client) writeMessage(message []byte) error {
+	this.connection.SetWriteDeadline(time.Now().Add(writeTimeout))
 	err := this.connection.WriteMessage(
 		websocket.BinaryMessage,
 		message,
@@ -74,9 +157,10 @@ func (this *client) sendMessage(message []byte) error {
 }
 
 func (this *client) receiveMessage() {
+	defer this.disconnectCallback(this.websocketRemoteAddress)
 	defer this.closeConnection()
 	for {
-		if this.connectionIsClosed {
+		if this.isClosed() {
 			return
 		}
 		_, message, err := this.connection.ReadMessage()
@@ -90,29 +174,30 @@ func (this *client) receiveMessage() {
 			)
 			return
 		}
-		go this.callback(this.websocketRemoteAddress, message)
+		this.callback(this, message)
 	}
 }
 
+// closeConnection stops accepting messages; the connection itself is
+// closed by the writer once the queued messages are written.
 func (this *client) closeConnection() {
+	this.mx.Lock()
+	defer this.mx.Unlock()
 	if this.connectionIsClosed {
 		return
 	}
 	this.connectionIsClosed = true
-	if err := this.connection.Close(); err != nil {
-		log.Println(
-			fmt.Sprintf(
-				"STREAMING [ERROR]: Connection with client [%s] closed with error [%s]",
-				this.websocketRemoteAddress,
-				err,
-			),
-		)
-	} else {
-		log.Println(
-			fmt.Sprintf(
-				"STREAMING [OK]: Connection with client [%s] closed",
-				this.websocketRemoteAddress,
-			),
-		)
-	}
+	close(this.closing)
+	log.Println(
+		fmt.Sprintf(
+			"STREAMING [OK]: Connection with client [%s] closed",
+			this.websocketRemoteAddress,
+		),
+	)
+}
+
+func (this *client) isClosed() bool {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	return this.connectionIsClosed
 }
@@ -0,0 +1,49 @@
+package streaming
+
+import "sync"
+
+type ConnectionHook func(clientRemoteAddress RemoteAddress)
+
+type connectionHooksManager struct {
+	onConnect    []ConnectionHook
+	onDisconnect []ConnectionHook
+	mx           *sync.RWMutex
+}
+
+func newConnectionHooksManager() *connectionHooksManager {
+	this := new(connectionHooksManager)
+	this.onConnect = make([]ConnectionHook, 0)
+	this.onDisconnect = make([]ConnectionHook, 0)
+	this.mx = new(sync.RWMutex)
+	return this
+}
+
+func (this *connectionHooksManager) registerOnConnect(hook ConnectionHook) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.onConnect = append(this.onConnect, hook)
+}
+
+func (this *connectionHooksManager) registerOnDisconnect(hook ConnectionHook) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.onDisconnect = append(this.onDisconnect, hook)
+}
+
+func (this *connectionHooksManager) connected(clientRemoteAddress RemoteAddress) {
+	this.mx.RLock()
+	hooks := this.onConnect
+	this.mx.RUnlock()
+	for _, hook := range hooks {
+		hook(clientRemoteAddress)
+	}
+}
+
+func (this *connectionHooksManager) disconnected(clientRemoteAddress RemoteAddress) {
+	this.mx.RLock()
+	hooks := this.onDisconnect
+	this.mx.RUnlock()
+	for _, hook := range hooks {
+		hook(clientRemoteAddress)
+	}
+}
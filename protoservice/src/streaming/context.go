@@ -0,0 +1,64 @@
+package streaming
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// abortIndex also caps the length of a handler chain.
+const abortIndex = math.MaxInt8 >> 1
+
+// Reply sends message to the client inside a Responce envelope
+// carrying the URI and request ID of the handled request.
+func (this *Context) Reply(message proto.Message) error {
+	return this.engine.SendResponceClient(this, message, nil)
+}
+
+// ReplyError sends an error Responce for the handled request.
+func (this *Context) ReplyError(err error) error {
+	return this.engine.SendResponceClient(this, nil, err)
+}
+
+// Param returns the value of a ":name" or "*name" segment of the route.
+func (this *Context) Param(name string) string {
+	return this.Params[name]
+}
+
+// Bind unmarshals the frame of the request into message and validates it
+// when message is a Validator.
+func (this *Context) Bind(message proto.Message) error {
+	if err := proto.Unmarshal(this.Frame, message); err != nil {
+		return ErrorFrameIsntUnmarshal
+	}
+	if validator, ok := message.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// Next runs the rest of the handler chain; a middleware calls it to hand
+// the request over and gets control back once the chain returns.
+func (this *Context) Next() {
+	this.index++
+	for this.index < len(this.handlers) {
+		this.handlers[this.index](this)
+		this.index++
+	}
+}
+
+// Abort stops the chain, the handlers after the current one aren't called.
+func (this *Context) Abort() {
+	this.index = abortIndex
+}
+
+// AbortWithError stops the chain and replies err to the client.
+func (this *Context) AbortWithError(err error) error {
+	this.Abort()
+	this.Error = err
+	return this.ReplyError(err)
+}
+
+func (this *Context) IsAborted() bool {
+	return this.index >= abortIndex
+}
@@ -4,27 +4,163 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
 type Engine struct {
-	PoolClients  *poolClientsManager
-	poolHandlers *handlersManager
-	rateLimiter  *rateLimitManager
+	PoolClients     *poolClientsManager
+	poolHandlers    *handlersManager
+	rateLimiter     *rateLimitManager
+	connectionHooks *connectionHooksManager
+	authenticator   Authenticator
+	metrics         *metricsManager
+	workersPool     *workersPoolManager
+	topics          *membershipsManager
+	topicPolicies   *topicPoliciesManager
+	groups          *membershipsManager
+	sendQueueSize   int
 }
 
+type Config struct {
+	PoolSizeClients int
+	// RateLimit applies to all messages of a client, URIRateLimits apply
+	// on top of it to the messages of a client sent to the URI.
+	RateLimit     RateLimit
+	URIRateLimits map[URI]RateLimit
+	// Workers is the number of goroutines running handlers, runtime.NumCPU()
+	// by default.
+	Workers int
+	// WorkerQueueSize is the number of messages of a client waiting for a
+	// worker before the client stops being read, 64 by default.
+	WorkerQueueSize int
+	// SendQueueSize is the number of messages waiting to be written to a
+	// client, 64 by default. Replies wait for room in the queue, broadcasts
+	// and publications are dropped for the client instead.
+	SendQueueSize int
+}
+
+const (
+	defaultWorkerQueueSize = 64
+	defaultSendQueueSize   = 64
+)
+
+// NewEngine disconnects clients sending over rateLimitPerSecond messages
+// per second, NewEngineWithConfig gives a finer control.
 func NewEngine(poolSizeClients int, rateLimitPerSecond int) *Engine {
+	return NewEngineWithConfig(Config{
+		PoolSizeClients: poolSizeClients,
+		RateLimit: RateLimit{
+			MessagesPerSecond: float64(rateLimitPerSecond),
+			Action:            RateLimitDisconnect,
+		},
+	})
+}
+
+func NewEngineWithConfig(config Config) *Engine {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.WorkerQueueSize <= 0 {
+		config.WorkerQueueSize = defaultWorkerQueueSize
+	}
+	if config.SendQueueSize <= 0 {
+		config.SendQueueSize = defaultSendQueueSize
+	}
 	this := new(Engine)
-	this.PoolClients = newPoolClientsManager(poolSizeClients)
+	this.PoolClients = newPoolClientsManager(config.PoolSizeClients)
 	this.poolHandlers = newHandlersManager()
-	this.rateLimiter = newRateLimitManager(rateLimitPerSecond, poolSizeClients)
-	go this.waitRateLimiterEvents()
+	this.rateLimiter = newRateLimitManager(config.RateLimit, config.URIRateLimits)
+	this.connectionHooks = newConnectionHooksManager()
+	this.metrics = newMetricsManager()
+	this.workersPool = newWorkersPoolManager(config.Workers, config.WorkerQueueSize, this.redirectMessageToHandler)
+	this.topics = newMembershipsManager()
+	this.topicPolicies = newTopicPoliciesManager()
+	this.groups = newMembershipsManager()
+	this.sendQueueSize = config.SendQueueSize
+	HandleProto(this, SubscribeURI, this.handleSubscribe)
+	HandleProto(this, UnsubscribeURI, this.handleUnsubscribe)
 	return this
 }
 
-func (this *Engine) Handle(uri URI, handle Handler) {
-	this.poolHandlers.registerHandler(uri, handle)
+// Handle registers a handler for uri; the middleware runs before it, after
+// the middleware added with Use. Segments of uri may be ":name" parameters
+// and the last one may be a "*name" wildcard, their values are available
+// with Context.Param. A uri can't be registered twice.
+func (this *Engine) Handle(uri URI, handle Handler, middleware ...Handler) error {
+	return this.HandleWithPolicy(uri, Policy{}, handle, middleware...)
+}
+
+// HandleWithPolicy registers a handler that is called only for principals
+// allowed by policy. Policies are enforced once an authenticator is set.
+func (this *Engine) HandleWithPolicy(uri URI, policy Policy, handle Handler, middleware ...Handler) error {
+	err := this.poolHandlers.registerHandler(uri, handle, policy, middleware)
+	if err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [ERROR]: Registering the handler [%s] failed. [error: %s]",
+				string(uri),
+				err.Error(),
+			),
+		)
+	}
+	return err
+}
+
+// Use adds middleware that runs before the handler of every URI. Like in
+// gin, a middleware may call context.Next() to run the rest of the chain
+// and act after it, and calls context.Abort() to stop the chain.
+func (this *Engine) Use(middleware ...Handler) error {
+	err := this.poolHandlers.use(middleware...)
+	if err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [ERROR]: Adding the middleware failed. [error: %s]",
+				err.Error(),
+			),
+		)
+	}
+	return err
+}
+
+// SetAuthenticator makes NewClient authenticate every upgrade request;
+// without an authenticator any request is upgraded.
+func (this *Engine) SetAuthenticator(authenticator Authenticator) {
+	this.authenticator = authenticator
+}
+
+// Authenticate checks the credentials of r with the engine authenticator,
+// so that plain HTTP endpoints share the websocket identities. Without an
+// authenticator the request stays anonymous.
+func (this *Engine) Authenticate(r *http.Request) (*Principal, error) {
+	if this.authenticator == nil {
+		return nil, nil
+	}
+	return this.authenticator.Authenticate(r)
+}
+
+// Authorize reports whether principal passes policy; every principal
+// passes while the engine has no authenticator.
+func (this *Engine) Authorize(principal *Principal, policy Policy) bool {
+	return this.authenticator == nil || policy.Allows(principal)
+}
+
+func (this *Engine) Metrics() Metrics {
+	return this.metrics.snapshot()
+}
+
+// OnConnect registers a hook called after a client is added to the pool.
+func (this *Engine) OnConnect(hook ConnectionHook) {
+	this.connectionHooks.registerOnConnect(hook)
+}
+
+// OnDisconnect registers a hook called once a client is removed from the
+// pool, whether the client went away or the engine closed the connection.
+func (this *Engine) OnDisconnect(hook ConnectionHook) {
+	this.connectionHooks.registerOnDisconnect(hook)
 }
 
 func (this *Engine) SendMessageClient(clientRemoteAddress RemoteAddress, message []byte) error {
@@ -36,6 +172,44 @@ func (this *Engine) SendMessageClient(clientRemoteAddress RemoteAddress, message
 	return err
 }
 
+func (this *Engine) SendResponceClient(context *Context, message proto.Message, responceError error) error {
+	responce := &Responce{
+		Uri:       string(context.URI),
+		RequestId: context.RequestID,
+	}
+	if responceError != nil {
+		responce.Error = responceError.Error()
+	}
+	if message != nil && message.ProtoReflect().IsValid() {
+		frame, err := proto.Marshal(message)
+		if err != nil {
+			log.Println(
+				fmt.Sprintf(
+					"STREAMING [ERROR]: Marshaling a responce [%s] for the client [%s] failed: [%s]",
+					string(context.URI),
+					string(context.ClientRemoteAddress),
+					err.Error(),
+				),
+			)
+			return err
+		}
+		responce.Frame = frame
+	}
+	readyMessage, err := proto.Marshal(responce)
+	if err != nil {
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [ERROR]: Marshaling a responce [%s] for the client [%s] failed: [%s]",
+				string(context.URI),
+				string(context.ClientRemoteAddress),
+				err.Error(),
+			),
+		)
+		return err
+	}
+	return this.SendMessageClient(context.ClientRemoteAddress, readyMessage)
+}
+
 func (this *Engine) NewClient(w http.ResponseWriter, r *http.Request) (RemoteAddress, error) {
 	if this.PoolClients.isFilled() {
 		log.Println(
@@ -46,17 +220,33 @@ func (this *Engine) NewClient(w http.ResponseWriter, r *http.Request) (RemoteAdd
 		)
 		return RemoteAddress(""), ErrorPoolClientIsFilled
 	}
-	client, err := newClient(w, r, this.redirectMessageToHandler)
+	var principal *Principal
+	if this.authenticator != nil {
+		authenticated, err := this.authenticator.Authenticate(r)
+		if err != nil {
+			log.Println(
+				fmt.Sprintf(
+					"STREAMING [ERROR]: Authentication of the client [%s] failed. [error: %s]",
+					r.RemoteAddr,
+					err.Error(),
+				),
+			)
+			return RemoteAddress(""), ErrorClientIsntAuthenticated
+		}
+		principal = authenticated
+	}
+	client, err := newClient(w, r, principal, this.sendQueueSize, this.receiveMessage, this.removeClient)
 	if err != nil {
 		log.Println(
 			fmt.Sprintf(
 				"STREAMING [ERROR]: Connection websocket with client [%s] open failed. [error: %s]",
-				client.websocketRemoteAddress,
+				r.RemoteAddr,
 				err.Error(),
 			),
 		)
 		return RemoteAddress(""), err
 	}
+	client.tasks = this.workersPool.newClientTasks(client)
 	err = this.PoolClients.push(client)
 	if err != nil {
 		log.Println(
@@ -66,6 +256,7 @@ func (this *Engine) NewClient(w http.ResponseWriter, r *http.Request) (RemoteAdd
 				err.Error(),
 			),
 		)
+		client.closeConnection()
 		return RemoteAddress(""), err
 	}
 	this.rateLimiter.startNewClientStatistic(client.websocketRemoteAddress)
@@ -75,6 +266,7 @@ func (this *Engine) NewClient(w http.ResponseWriter, r *http.Request) (RemoteAdd
 			client.websocketRemoteAddress,
 		),
 	)
+	this.connectionHooks.connected(client.websocketRemoteAddress)
 	go client.receiveMessage()
 	return client.websocketRemoteAddress, nil
 }
@@ -91,29 +283,46 @@ func (this *Engine) CloseConnectionClient(clientRemoteAddress RemoteAddress) {
 		return
 	}
 	client.closeConnection()
-	err = this.PoolClients.delete(client.websocketRemoteAddress)
-	if err != nil {
-		log.Println(
-			fmt.Sprintf(
-				"STREAMING [ERROR]: Attempt to delete a non-existent client [%s]",
-				string(clientRemoteAddress),
-			),
-		)
+	this.removeClient(clientRemoteAddress)
+}
+
+// Close closes the connections of all clients and stops the workers once
+// their current handlers return.
+func (this *Engine) Close() {
+	for tuple := range this.PoolClients.Iterate() {
+		this.CloseConnectionClient(tuple.Address)
+	}
+	this.workersPool.close()
+}
+
+// removeClient is called both by CloseConnectionClient and by the client
+// itself when its receive loop exits; only the first call runs the
+// disconnect hooks.
+func (this *Engine) removeClient(clientRemoteAddress RemoteAddress) {
+	if err := this.PoolClients.delete(clientRemoteAddress); err != nil {
 		return
 	}
 	this.rateLimiter.deleteClientStatistic(clientRemoteAddress)
+	this.topics.removeAll(clientRemoteAddress)
+	this.groups.removeAll(clientRemoteAddress)
 	log.Println(
 		fmt.Sprintf(
 			"STREAMING [OK]: Connection with client [%s] closed successfully",
 			string(clientRemoteAddress),
 		),
 	)
+	this.connectionHooks.disconnected(clientRemoteAddress)
 }
 
-func (this *Engine) redirectMessageToHandler(clientRemoteAddress RemoteAddress, message []byte) {
+// receiveMessage runs in the read loop of the client: it applies the rate
+// limits, so a delayed client isn't read meanwhile, and passes the request
+// to the worker of the client.
+func (this *Engine) receiveMessage(client *client, message []byte) {
+	clientRemoteAddress := client.websocketRemoteAddress
 	request := new(Request)
-	this.rateLimiter.updateClientStatistic(clientRemoteAddress)
+	this.metrics.messageReceived()
 	if err := proto.Unmarshal(message, request); err != nil {
+		this.metrics.messageRejected()
 		log.Println(
 			fmt.Sprintf(
 				"STREAMING [ERROR]: An error occurred while receiving a message from the client [%s] and transmitting it to the handler: [%s]",
@@ -121,11 +330,60 @@ func (this *Engine) redirectMessageToHandler(clientRemoteAddress RemoteAddress,
 				err.Error(),
 			),
 		)
+		this.SendResponceClient(&Context{
+			ClientRemoteAddress: clientRemoteAddress,
+			Principal:           client.principal,
+			Message:             message,
+			engine:              this,
+		}, nil, ErrorRequestIsntUnmarshal)
+		return
+	}
+	wait, action, allowed := this.rateLimiter.take(clientRemoteAddress, URI(request.GetUri()), len(message))
+	if !allowed {
+		this.metrics.messageRateLimited()
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [WARNING]: Client [%s] exceeded the rate limit of the handler [%s]",
+				string(clientRemoteAddress),
+				request.GetUri(),
+			),
+		)
+		this.SendResponceClient(&Context{
+			ClientRemoteAddress: clientRemoteAddress,
+			URI:                 URI(request.GetUri()),
+			RequestID:           request.GetRequestId(),
+			engine:              this,
+		}, nil, ErrorRateLimitExceeded)
+		if action == RateLimitDisconnect {
+			this.CloseConnectionClient(clientRemoteAddress)
+		}
 		return
 	}
+	if wait > 0 {
+		this.metrics.messageDelayed()
+		time.Sleep(wait)
+	}
+	this.workersPool.dispatch(client.tasks, request, message)
+}
+
+func (this *Engine) redirectMessageToHandler(client *client, request *Request, message []byte) {
+	clientRemoteAddress := client.websocketRemoteAddress
 	uri := request.GetUri()
-	handler, err := this.poolHandlers.getHandler(URI(uri))
+	context := &Context{
+		ClientRemoteAddress: clientRemoteAddress,
+		Principal:           client.principal,
+		URI:                 URI(uri),
+		RequestID:           request.GetRequestId(),
+		Metadata:            request.GetMetadata(),
+		Message:             message,
+		Frame:               request.GetFrame(),
+		Error:               nil,
+		engine:              this,
+	}
+	defer this.recoverHandlerPanic(context)
+	handlers, policy, params, err := this.poolHandlers.getHandlers(URI(uri))
 	if err != nil {
+		this.metrics.messageRejected()
 		log.Println(
 			fmt.Sprintf(
 				"STREAMING [ERROR]: An error occurred while receiving a message from the client [%s] and transmitting it to the handler: [%s]",
@@ -133,6 +391,24 @@ func (this *Engine) redirectMessageToHandler(clientRemoteAddress RemoteAddress,
 				err.Error(),
 			),
 		)
+		this.SendResponceClient(context, nil, err)
+		return
+	}
+	if !this.Authorize(client.principal, policy) {
+		this.metrics.messageRejected()
+		subject := ""
+		if client.principal != nil {
+			subject = client.principal.Subject
+		}
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [WARNING]: Access to the handler [%s] is denied for the client [%s] with principal [%s]",
+				uri,
+				string(clientRemoteAddress),
+				subject,
+			),
+		)
+		this.SendResponceClient(context, nil, ErrorAccessIsDenied)
 		return
 	}
 	log.Println(
@@ -142,27 +418,28 @@ func (this *Engine) redirectMessageToHandler(clientRemoteAddress RemoteAddress,
 			string(clientRemoteAddress),
 		),
 	)
-	handler(&Context{
-		ClientRemoteAddress: clientRemoteAddress,
-		Message:             message,
-		Error:               nil,
-	})
+	context.Params = params
+	context.handlers = handlers
+	context.index = -1
+	context.Next()
 }
 
-func (this *Engine) waitRateLimiterEvents() {
-	for clientRemoteAddress := range this.rateLimiter.channelConnectionCloseEvent {
-		client, err := this.PoolClients.Get(clientRemoteAddress)
-		if err != nil {
-			continue
-		}
-		client.closeConnection()
-		this.PoolClients.delete(clientRemoteAddress)
-		this.rateLimiter.deleteClientStatistic(clientRemoteAddress)
-		log.Println(
-			fmt.Sprintf(
-				"STREAMING [WARNING]: Сlient [%s] exceeded the allowed number of requests and was disconnected.",
-				string(clientRemoteAddress),
-			),
-		)
+// recoverHandlerPanic keeps a panicking handler from taking the process
+// down, the client gets an internal error for its request.
+func (this *Engine) recoverHandlerPanic(context *Context) {
+	recovered := recover()
+	if recovered == nil {
+		return
 	}
+	this.metrics.panicRecovered()
+	log.Println(
+		fmt.Sprintf(
+			"STREAMING [ERROR]: Handler [%s] panicked on request of the client [%s]: [%v]\n%s",
+			string(context.URI),
+			string(context.ClientRemoteAddress),
+			recovered,
+			debug.Stack(),
+		),
+	)
+	this.SendResponceClient(context, nil, ErrorInternal)
 }
@@ -1,35 +1,179 @@
 package streaming
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
+
+type route struct {
+	handlers []Handler
+	policy   Policy
+}
+
+// routeNode is a segment of the registered URIs. A segment is matched
+// exactly, by a ":name" parameter or by a trailing "*name" wildcard that
+// captures the rest of the URI, in that order of preference.
+type routeNode struct {
+	static       map[string]*routeNode
+	param        *routeNode
+	paramName    string
+	wildcard     *route
+	wildcardName string
+	route        *route
+}
 
 type handlersManager struct {
-	handlers     map[URI]Handler
+	root       *routeNode
+	middleware []Handler
+	// longestRoute is the length of the longest route chain, together with
+	// the engine middleware it has to stay under abortIndex
+	longestRoute int
 	mx           *sync.RWMutex
 }
 
 func newHandlersManager() *handlersManager {
 	this := new(handlersManager)
-	this.handlers = make(map[URI]Handler, 0)
+	this.root = newRouteNode()
+	this.middleware = make([]Handler, 0)
 	this.mx = new(sync.RWMutex)
 	return this
 }
 
-func (this *handlersManager) registerHandler(uri URI, handle Handler) {
+func newRouteNode() *routeNode {
+	this := new(routeNode)
+	this.static = make(map[string]*routeNode)
+	return this
+}
+
+func (this *handlersManager) use(middleware ...Handler) error {
 	this.mx.Lock()
 	defer this.mx.Unlock()
-	if _, exist := this.handlers[uri]; exist {
-		return
+	if len(this.middleware)+len(middleware)+this.longestRoute >= abortIndex {
+		return ErrorHandlersAreTooMany
+	}
+	this.middleware = append(this.middleware, middleware...)
+	return nil
+}
+
+func (this *handlersManager) registerHandler(uri URI, handle Handler, policy Policy, middleware []Handler) error {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if len(this.middleware)+len(middleware)+1 >= abortIndex {
+		return ErrorHandlersAreTooMany
+	}
+	handlers := make([]Handler, 0, len(middleware)+1)
+	handlers = append(handlers, middleware...)
+	handlers = append(handlers, handle)
+	newRoute := &route{
+		handlers: handlers,
+		policy:   policy,
+	}
+	segments := splitURI(uri)
+	if err := validateSegments(segments); err != nil {
+		return err
+	}
+	// a conflict can only be met on the existing nodes, before any node of
+	// the route is added, so a rejected route leaves the tree unchanged
+	node := this.root
+	for _, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			if node.wildcard != nil {
+				return ErrorHandlerIsExist
+			}
+			node.wildcard = newRoute
+			node.wildcardName = segment[1:]
+			this.updateLongestRoute(newRoute)
+			return nil
+		case strings.HasPrefix(segment, ":"):
+			if node.param == nil {
+				node.param = newRouteNode()
+				node.paramName = segment[1:]
+			} else if node.paramName != segment[1:] {
+				return ErrorRouteIsntValid
+			}
+			node = node.param
+		default:
+			next, exist := node.static[segment]
+			if !exist {
+				next = newRouteNode()
+				node.static[segment] = next
+			}
+			node = next
+		}
+	}
+	if node.route != nil {
+		return ErrorHandlerIsExist
+	}
+	node.route = newRoute
+	this.updateLongestRoute(newRoute)
+	return nil
+}
+
+func (this *handlersManager) updateLongestRoute(route *route) {
+	if len(route.handlers) > this.longestRoute {
+		this.longestRoute = len(route.handlers)
 	}
-	this.handlers[uri] = handle
 }
 
-func (this *handlersManager) getHandler(uri URI) (Handler, error) {
+func validateSegments(segments []string) error {
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, "*") && index != len(segments)-1 {
+			return ErrorRouteIsntValid
+		}
+		if (strings.HasPrefix(segment, "*") || strings.HasPrefix(segment, ":")) && len(segment) == 1 {
+			return ErrorRouteIsntValid
+		}
+	}
+	return nil
+}
+
+// getHandlers returns the engine middleware followed by the route
+// middleware and the route handler, along with the captured parameters.
+func (this *handlersManager) getHandlers(uri URI) ([]Handler, Policy, map[string]string, error) {
 	this.mx.RLock()
 	defer this.mx.RUnlock()
-	if handle, exist := this.handlers[uri]; !exist {
-		return nil, ErrorHandlerIsntExist
-	} else {
-		return handle, nil
+	params := make(map[string]string)
+	route := this.root.match(splitURI(uri), params)
+	if route == nil {
+		return nil, Policy{}, nil, ErrorHandlerIsntExist
 	}
+	handlers := make([]Handler, 0, len(this.middleware)+len(route.handlers))
+	handlers = append(handlers, this.middleware...)
+	handlers = append(handlers, route.handlers...)
+	return handlers, route.policy, params, nil
 }
 
+func (this *routeNode) match(segments []string, params map[string]string) *route {
+	if len(segments) == 0 {
+		if this.route == nil && this.wildcard != nil {
+			params[this.wildcardName] = ""
+			return this.wildcard
+		}
+		return this.route
+	}
+	if next, exist := this.static[segments[0]]; exist {
+		if route := next.match(segments[1:], params); route != nil {
+			return route
+		}
+	}
+	if this.param != nil && segments[0] != "" {
+		if route := this.param.match(segments[1:], params); route != nil {
+			params[this.paramName] = segments[0]
+			return route
+		}
+	}
+	if this.wildcard != nil {
+		params[this.wildcardName] = strings.Join(segments, "/")
+		return this.wildcard
+	}
+	return nil
+}
+
+func splitURI(uri URI) []string {
+	trimmed := strings.Trim(string(uri), "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
@@ -0,0 +1,67 @@
+package streaming
+
+import "sync"
+
+// membershipsManager keeps the clients of named sets, it backs both the
+// topic subscriptions and the client groups.
+type membershipsManager struct {
+	members     map[string]map[RemoteAddress]struct{}
+	memberships map[RemoteAddress]map[string]struct{}
+	mx          *sync.RWMutex
+}
+
+func newMembershipsManager() *membershipsManager {
+	this := new(membershipsManager)
+	this.members = make(map[string]map[RemoteAddress]struct{})
+	this.memberships = make(map[RemoteAddress]map[string]struct{})
+	this.mx = new(sync.RWMutex)
+	return this
+}
+
+func (this *membershipsManager) add(clientRemoteAddress RemoteAddress, name string) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if _, exist := this.members[name]; !exist {
+		this.members[name] = make(map[RemoteAddress]struct{})
+	}
+	this.members[name][clientRemoteAddress] = struct{}{}
+	if _, exist := this.memberships[clientRemoteAddress]; !exist {
+		this.memberships[clientRemoteAddress] = make(map[string]struct{})
+	}
+	this.memberships[clientRemoteAddress][name] = struct{}{}
+}
+
+func (this *membershipsManager) remove(clientRemoteAddress RemoteAddress, name string) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.deleteMembership(clientRemoteAddress, name)
+}
+
+func (this *membershipsManager) removeAll(clientRemoteAddress RemoteAddress) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	for name := range this.memberships[clientRemoteAddress] {
+		this.deleteMembership(clientRemoteAddress, name)
+	}
+}
+
+func (this *membershipsManager) deleteMembership(clientRemoteAddress RemoteAddress, name string) {
+	delete(this.members[name], clientRemoteAddress)
+	if len(this.members[name]) == 0 {
+		delete(this.members, name)
+	}
+	delete(this.memberships[clientRemoteAddress], name)
+	if len(this.memberships[clientRemoteAddress]) == 0 {
+		delete(this.memberships, clientRemoteAddress)
+	}
+}
+
+func (this *membershipsManager) getMembers(name string) []RemoteAddress {
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	members := make([]RemoteAddress, 0, len(this.members[name]))
+	for clientRemoteAddress := range this.members[name] {
+		members = append(members, clientRemoteAddress)
+	}
+	return members
+}
@@ -0,0 +1,61 @@
+package streaming
+
+import "sync/atomic"
+
+// Metrics is a snapshot of the engine counters.
+type Metrics struct {
+	ReceivedMessages    uint64
+	RejectedMessages    uint64
+	RateLimitedMessages uint64
+	DelayedMessages     uint64
+	DroppedMessages     uint64
+	RecoveredPanics     uint64
+}
+
+type metricsManager struct {
+	receivedMessages    uint64
+	rejectedMessages    uint64
+	rateLimitedMessages uint64
+	delayedMessages     uint64
+	droppedMessages     uint64
+	recoveredPanics     uint64
+}
+
+func newMetricsManager() *metricsManager {
+	return new(metricsManager)
+}
+
+func (this *metricsManager) messageReceived() {
+	atomic.AddUint64(&this.receivedMessages, 1)
+}
+
+func (this *metricsManager) messageRejected() {
+	atomic.AddUint64(&this.rejectedMessages, 1)
+}
+
+func (this *metricsManager) messageRateLimited() {
+	atomic.AddUint64(&this.rateLimitedMessages, 1)
+}
+
+func (this *metricsManager) messageDelayed() {
+	atomic.AddUint64(&this.delayedMessages, 1)
+}
+
+func (this *metricsManager) messageDropped() {
+	atomic.AddUint64(&this.droppedMessages, 1)
+}
+
+func (this *metricsManager) panicRecovered() {
+	atomic.AddUint64(&this.recoveredPanics, 1)
+}
+
+func (this *metricsManager) snapshot() Metrics {
+	return Metrics{
+		ReceivedMessages:    atomic.LoadUint64(&this.receivedMessages),
+		RejectedMessages:    atomic.LoadUint64(&this.rejectedMessages),
+		RateLimitedMessages: atomic.LoadUint64(&this.rateLimitedMessages),
+		DelayedMessages:     atomic.LoadUint64(&this.delayedMessages),
+		DroppedMessages:     atomic.LoadUint64(&this.droppedMessages),
+		RecoveredPanics:     atomic.LoadUint64(&this.recoveredPanics),
+	}
+}
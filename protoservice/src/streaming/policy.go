@@ -0,0 +1,29 @@
+package streaming
+
+import "errors"
+
+// Policy declares who may call a URI: a principal passes when it has any
+// of the roles or any of the scopes. An empty policy lets everybody in.
+type Policy struct {
+	Roles  []string
+	Scopes []string
+}
+
+var ErrorAccessIsDenied = errors.New("Error: access is denied")
+
+func (this Policy) Allows(principal *Principal) bool {
+	if len(this.Roles) == 0 && len(this.Scopes) == 0 {
+		return true
+	}
+	for _, role := range this.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	for _, scope := range this.Scopes {
+		if principal.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
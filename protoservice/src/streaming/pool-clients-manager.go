@@ -70,19 +70,17 @@ func (this *poolClientsManager) delete(remoteAddress RemoteAddress) error {
 	return nil
 }
 
+// Iterate returns the clients of the pool at the moment of the call.
 func (this *poolClientsManager) Iterate() <-chan clientTuple {
-	var (
-		channel = make(chan clientTuple, this.length())
-		write   = func(channel chan clientTuple, this *poolClientsManager) {
-			for remoteAddress, client := range this.pool {
-				channel <- clientTuple{
-					Address: remoteAddress,
-					Client:  client,
-				}
-			}
-			close(channel)
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	channel := make(chan clientTuple, len(this.pool))
+	for remoteAddress, client := range this.pool {
+		channel <- clientTuple{
+			Address: remoteAddress,
+			Client:  client,
 		}
-	)
-	go write(channel, this)
+	}
+	close(channel)
 	return channel
 }
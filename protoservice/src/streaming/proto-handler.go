@@ -0,0 +1,67 @@
+package streaming
+
+import (
+	"fmt"
+	"log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoHandlerFunc handles a decoded request and returns the message to
+// reply with, or the error to reply instead.
+type ProtoHandlerFunc[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}] func(context *Context, request PReq) (Resp, error)
+
+// ProtoHandler adapts a typed handler to Handler: the frame is bound into
+// a new Req (with validation), and the returned message or error is sent
+// back in a Responce.
+func ProtoHandler[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}](handle ProtoHandlerFunc[Req, Resp, PReq]) Handler {
+	return func(context *Context) {
+		request := PReq(new(Req))
+		if err := context.Bind(request); err != nil {
+			logProtoHandlerError(context, err)
+			context.ReplyError(err)
+			return
+		}
+		responce, err := handle(context, request)
+		if err != nil {
+			logProtoHandlerError(context, err)
+			context.ReplyError(err)
+			return
+		}
+		context.Reply(responce)
+	}
+}
+
+// HandleProto registers a typed handler for uri, see ProtoHandler.
+func HandleProto[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}](router Router, uri URI, handle ProtoHandlerFunc[Req, Resp, PReq], middleware ...Handler) error {
+	return router.Handle(uri, ProtoHandler(handle), middleware...)
+}
+
+// HandleProtoWithPolicy registers a typed handler for uri guarded by
+// policy, see ProtoHandler and Engine.HandleWithPolicy.
+func HandleProtoWithPolicy[Req any, Resp proto.Message, PReq interface {
+	*Req
+	proto.Message
+}](router Router, uri URI, policy Policy, handle ProtoHandlerFunc[Req, Resp, PReq], middleware ...Handler) error {
+	return router.HandleWithPolicy(uri, policy, ProtoHandler(handle), middleware...)
+}
+
+func logProtoHandlerError(context *Context, err error) {
+	log.Println(
+		fmt.Sprintf(
+			"STREAMING [ERROR]: Handler [%s] failed for the client [%s]. [error: %s]",
+			string(context.URI),
+			string(context.ClientRemoteAddress),
+			err.Error(),
+		),
+	)
+}
@@ -5,63 +5,173 @@ import (
 	"time"
 )
 
+type RateLimitAction int
+
+const (
+	// RateLimitReject replies ErrorRateLimitExceeded to the messages over
+	// the limit.
+	RateLimitReject RateLimitAction = iota
+	// RateLimitDelay holds the client's messages until the limit lets them
+	// through, reading from the client is paused meanwhile.
+	RateLimitDelay
+	// RateLimitDisconnect closes the connection of the client.
+	RateLimitDisconnect
+)
+
+// RateLimit is a pair of token buckets refilled with MessagesPerSecond
+// messages and BytesPerSecond bytes, which hold at most MessagesBurst and
+// BytesBurst of them. A zero rate doesn't limit, a zero burst equals the
+// rate of one second.
+type RateLimit struct {
+	MessagesPerSecond float64
+	MessagesBurst     int
+	BytesPerSecond    float64
+	BytesBurst        int
+	Action            RateLimitAction
+}
+
+type tokenBucket struct {
+	rate      float64
+	capacity  float64
+	tokens    float64
+	updatedAt time.Time
+}
+
+type clientRateLimits struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+	uris     map[URI][2]*tokenBucket
+}
+
 type rateLimitManager struct {
-	rateLimitPerSecond          int
-	clientRequestStatistics     map[RemoteAddress]int
-	mx                          *sync.RWMutex
-	channelConnectionCloseEvent chan RemoteAddress
+	rateLimit     RateLimit
+	uriRateLimits map[URI]RateLimit
+	clients       map[RemoteAddress]*clientRateLimits
+	mx            *sync.Mutex
 }
 
-func newRateLimitManager(rateLimitPerSecond int, poolSizeClients int) *rateLimitManager {
+func newRateLimitManager(rateLimit RateLimit, uriRateLimits map[URI]RateLimit) *rateLimitManager {
 	this := new(rateLimitManager)
-	this.mx = new(sync.RWMutex)
-	this.rateLimitPerSecond = rateLimitPerSecond
-	this.clientRequestStatistics = make(map[RemoteAddress]int)
-	this.channelConnectionCloseEvent = make(chan RemoteAddress, poolSizeClients)
-	go this.checkClientStatistics()
+	this.mx = new(sync.Mutex)
+	this.rateLimit = rateLimit
+	this.uriRateLimits = make(map[URI]RateLimit, len(uriRateLimits))
+	for uri, rateLimit := range uriRateLimits {
+		this.uriRateLimits[uri] = rateLimit
+	}
+	this.clients = make(map[RemoteAddress]*clientRateLimits)
 	return this
 }
 
 func (this *rateLimitManager) startNewClientStatistic(clientRemoteAddress RemoteAddress) {
 	this.mx.Lock()
 	defer this.mx.Unlock()
-	this.clientRequestStatistics[clientRemoteAddress] = 0
+	now := time.Now()
+	this.clients[clientRemoteAddress] = &clientRateLimits{
+		messages: newTokenBucket(this.rateLimit.MessagesPerSecond, this.rateLimit.MessagesBurst, now),
+		bytes:    newTokenBucket(this.rateLimit.BytesPerSecond, this.rateLimit.BytesBurst, now),
+		uris:     make(map[URI][2]*tokenBucket),
+	}
 }
 
-func (this *rateLimitManager) updateClientStatistic(clientRemoteAddress RemoteAddress) {
+func (this *rateLimitManager) deleteClientStatistic(clientRemoteAddress RemoteAddress) {
 	this.mx.Lock()
 	defer this.mx.Unlock()
-	if stat, exist := this.clientRequestStatistics[clientRemoteAddress]; !exist {
-		return
-	} else {
-		this.clientRequestStatistics[clientRemoteAddress] = stat + 1
-	}
+	delete(this.clients, clientRemoteAddress)
 }
 
-func (this *rateLimitManager) deleteClientStatistic(clientRemoteAddress RemoteAddress) {
+// take spends the tokens of a message of size bytes sent to uri. When a
+// bucket lacks tokens the message isn't allowed and nothing is spent,
+// unless the violated limits only delay messages: then the tokens are
+// borrowed and the message is allowed after the returned duration.
+func (this *rateLimitManager) take(clientRemoteAddress RemoteAddress, uri URI, size int) (time.Duration, RateLimitAction, bool) {
 	this.mx.Lock()
 	defer this.mx.Unlock()
-	if _, exist := this.clientRequestStatistics[clientRemoteAddress]; !exist {
-		return
-	} else {
-		delete(this.clientRequestStatistics, clientRemoteAddress)
+	client, exist := this.clients[clientRemoteAddress]
+	if !exist {
+		return 0, RateLimitReject, true
+	}
+	now := time.Now()
+	buckets := []*tokenBucket{client.messages, client.bytes}
+	costs := []float64{1, float64(size)}
+	actions := []RateLimitAction{this.rateLimit.Action, this.rateLimit.Action}
+	if uriRateLimit, exist := this.uriRateLimits[uri]; exist {
+		uriBuckets, exist := client.uris[uri]
+		if !exist {
+			uriBuckets = [2]*tokenBucket{
+				newTokenBucket(uriRateLimit.MessagesPerSecond, uriRateLimit.MessagesBurst, now),
+				newTokenBucket(uriRateLimit.BytesPerSecond, uriRateLimit.BytesBurst, now),
+			}
+			client.uris[uri] = uriBuckets
+		}
+		buckets = append(buckets, uriBuckets[0], uriBuckets[1])
+		costs = append(costs, 1, float64(size))
+		actions = append(actions, uriRateLimit.Action, uriRateLimit.Action)
 	}
-}
-
-func (this *rateLimitManager) checkClientStatistics() {
 	var (
-		ticker = time.NewTicker(time.Second)
+		wait   time.Duration
+		action = RateLimitDelay
 	)
-	for {
-		select {
-		case <-ticker.C:
-			for client, stat := range this.clientRequestStatistics {
-				if stat >= this.rateLimitPerSecond {
-					this.channelConnectionCloseEvent <- client
-				} else {
-					this.clientRequestStatistics[client] = 0
-				}
-			}
+	for index, bucket := range buckets {
+		bucketWait := bucket.wait(costs[index], now)
+		if bucketWait == 0 {
+			continue
+		}
+		if bucketWait > wait {
+			wait = bucketWait
+		}
+		// the strictest of the violated limits decides
+		if actions[index] == RateLimitDisconnect || (actions[index] == RateLimitReject && action == RateLimitDelay) {
+			action = actions[index]
 		}
 	}
+	if wait > 0 && action != RateLimitDelay {
+		return 0, action, false
+	}
+	for index, bucket := range buckets {
+		bucket.spend(costs[index])
+	}
+	return wait, RateLimitDelay, true
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	this := new(tokenBucket)
+	this.rate = rate
+	this.capacity = float64(burst)
+	if this.capacity <= 0 {
+		this.capacity = rate
+	}
+	this.tokens = this.capacity
+	this.updatedAt = now
+	return this
+}
+
+// wait refills the bucket and returns how long cost tokens are missing
+// for. A cost over the capacity is charged as the whole capacity, so that
+// one large message still passes a full bucket.
+func (this *tokenBucket) wait(cost float64, now time.Time) time.Duration {
+	if this.rate <= 0 {
+		return 0
+	}
+	this.tokens += now.Sub(this.updatedAt).Seconds() * this.rate
+	if this.tokens > this.capacity {
+		this.tokens = this.capacity
+	}
+	this.updatedAt = now
+	if cost > this.capacity {
+		cost = this.capacity
+	}
+	if this.tokens >= cost {
+		return 0
+	}
+	return time.Duration((cost - this.tokens) / this.rate * float64(time.Second))
+}
+
+func (this *tokenBucket) spend(cost float64) {
+	if this.rate <= 0 {
+		return
+	}
+	if cost > this.capacity {
+		cost = this.capacity
+	}
+	this.tokens -= cost
 }
@@ -0,0 +1,52 @@
+package streaming
+
+import "strings"
+
+// Router registers handlers, it's implemented by Engine and RouteGroup.
+type Router interface {
+	Handle(uri URI, handle Handler, middleware ...Handler) error
+	HandleWithPolicy(uri URI, policy Policy, handle Handler, middleware ...Handler) error
+	Group(prefix URI, middleware ...Handler) *RouteGroup
+}
+
+// RouteGroup registers handlers under a shared URI prefix, running the
+// group middleware before the middleware of each route.
+type RouteGroup struct {
+	engine     *Engine
+	prefix     URI
+	middleware []Handler
+}
+
+func (this *Engine) Group(prefix URI, middleware ...Handler) *RouteGroup {
+	group := new(RouteGroup)
+	group.engine = this
+	group.prefix = prefix
+	group.middleware = append([]Handler(nil), middleware...)
+	return group
+}
+
+// Use adds middleware to the routes registered on the group afterwards.
+func (this *RouteGroup) Use(middleware ...Handler) {
+	this.middleware = append(this.middleware, middleware...)
+}
+
+func (this *RouteGroup) Group(prefix URI, middleware ...Handler) *RouteGroup {
+	group := this.engine.Group(this.uri(prefix), this.middleware...)
+	group.Use(middleware...)
+	return group
+}
+
+func (this *RouteGroup) Handle(uri URI, handle Handler, middleware ...Handler) error {
+	return this.HandleWithPolicy(uri, Policy{}, handle, middleware...)
+}
+
+func (this *RouteGroup) HandleWithPolicy(uri URI, policy Policy, handle Handler, middleware ...Handler) error {
+	handlers := make([]Handler, 0, len(this.middleware)+len(middleware))
+	handlers = append(handlers, this.middleware...)
+	handlers = append(handlers, middleware...)
+	return this.engine.HandleWithPolicy(this.uri(uri), policy, handle, handlers...)
+}
+
+func (this *RouteGroup) uri(uri URI) URI {
+	return URI(strings.TrimSuffix(string(this.prefix), "/") + "/" + strings.TrimPrefix(string(uri), "/"))
+}
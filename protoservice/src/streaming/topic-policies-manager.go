@@ -0,0 +1,42 @@
+package streaming
+
+import (
+	"strings"
+	"sync"
+)
+
+// topicPoliciesManager keeps the policies of the topics. Topics are
+// hierarchical: the policy of "files" also applies to "files/42" unless
+// "files/42" has its own one.
+type topicPoliciesManager struct {
+	policies map[string]Policy
+	mx       *sync.RWMutex
+}
+
+func newTopicPoliciesManager() *topicPoliciesManager {
+	this := new(topicPoliciesManager)
+	this.policies = make(map[string]Policy)
+	this.mx = new(sync.RWMutex)
+	return this
+}
+
+func (this *topicPoliciesManager) set(topic string, policy Policy) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	this.policies[topic] = policy
+}
+
+func (this *topicPoliciesManager) get(topic string) Policy {
+	this.mx.RLock()
+	defer this.mx.RUnlock()
+	for {
+		if policy, exist := this.policies[topic]; exist {
+			return policy
+		}
+		index := strings.LastIndex(topic, "/")
+		if index < 0 {
+			return Policy{}
+		}
+		topic = topic[:index]
+	}
+}
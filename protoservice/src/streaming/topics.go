@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// SubscribeURI and UnsubscribeURI are served by the engine itself, they
+	// take a TopicSubscription and reply with it.
+	SubscribeURI   URI = "/topic/subscribe"
+	UnsubscribeURI URI = "/topic/unsubscribe"
+	// PublishURI is the URI of the Responce pushed to the subscribers of a
+	// topic; it has no request ID and names the topic.
+	PublishURI URI = "/topic/publish"
+)
+
+var ErrorTopicIsEmpty = errors.New("Error: topic is empty")
+
+func (this *TopicSubscription) Validate() error {
+	if this.GetTopic() == "" {
+		return ErrorTopicIsEmpty
+	}
+	return nil
+}
+
+// Publish pushes message to every client subscribed to topic and returns
+// the number of clients it was queued for.
+func (this *Engine) Publish(topic string, message proto.Message) (int, error) {
+	clients := make([]*client, 0)
+	for _, clientRemoteAddress := range this.topics.getMembers(topic) {
+		client, err := this.PoolClients.Get(clientRemoteAddress)
+		if err != nil {
+			// the client subscribed while its connection was being closed
+			this.topics.removeAll(clientRemoteAddress)
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return this.fanOut(clients, &Responce{
+		Uri:   string(PublishURI),
+		Topic: topic,
+	}, message)
+}
+
+// SetTopicPolicy makes subscribing to topic and to its subtopics ("topic/...")
+// require policy. Topics without a policy are open to every client.
+func (this *Engine) SetTopicPolicy(topic string, policy Policy) {
+	this.topicPolicies.set(topic, policy)
+}
+
+func (this *Engine) handleSubscribe(context *Context, subscription *TopicSubscription) (*TopicSubscription, error) {
+	if !this.Authorize(context.Principal, this.topicPolicies.get(subscription.GetTopic())) {
+		log.Println(
+			fmt.Sprintf(
+				"STREAMING [WARNING]: Subscription to the topic [%s] is denied for the client [%s]",
+				subscription.GetTopic(),
+				string(context.ClientRemoteAddress),
+			),
+		)
+		return nil, ErrorAccessIsDenied
+	}
+	this.topics.add(context.ClientRemoteAddress, subscription.GetTopic())
+	return subscription, nil
+}
+
+func (this *Engine) handleUnsubscribe(context *Context, subscription *TopicSubscription) (*TopicSubscription, error) {
+	this.topics.remove(context.ClientRemoteAddress, subscription.GetTopic())
+	return subscription, nil
+}
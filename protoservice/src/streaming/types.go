@@ -5,16 +5,38 @@ import "errors"
 type (
 	Context struct {
 		ClientRemoteAddress RemoteAddress
+		Principal           *Principal
+		URI                 URI
+		RequestID           string
+		Metadata            map[string]string
+		Params              map[string]string
 		Message             []byte
+		Frame               []byte
 		Error               error
+		engine              *Engine
+		handlers            []Handler
+		index               int
 	}
 	URI           string
 	RemoteAddress string
 	Handler       func(context *Context)
+	// Validator is implemented by messages checking themselves once
+	// Context.Bind has unmarshalled them.
+	Validator interface {
+		Validate() error
+	}
 )
 
 var (
-	ErrorPoolClientIsFilled = errors.New("Error: pool client is filled")
-	ErrorClientObjectIsNil  = errors.New("Error: client object is nil")
-	ErrorHandlerIsntExist   = errors.New("Error: handler isn't exist")
+	ErrorPoolClientIsFilled   = errors.New("Error: pool client is filled")
+	ErrorClientObjectIsNil    = errors.New("Error: client object is nil")
+	ErrorHandlerIsntExist     = errors.New("Error: handler isn't exist")
+	ErrorHandlerIsExist       = errors.New("Error: handler is already exist")
+	ErrorRouteIsntValid       = errors.New("Error: route isn't valid")
+	ErrorHandlersAreTooMany   = errors.New("Error: handler chain is too long")
+	ErrorConnectionIsClosed   = errors.New("Error: connection is closed")
+	ErrorRequestIsntUnmarshal = errors.New("Error: request isn't unmarshal")
+	ErrorFrameIsntUnmarshal   = errors.New("Error: frame isn't unmarshal")
+	ErrorInternal             = errors.New("Error: internal server error")
+	ErrorRateLimitExceeded    = errors.New("Error: rate limit exceeded")
 )
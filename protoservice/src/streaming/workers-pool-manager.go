@@ -0,0 +1,122 @@
+package streaming
+
+import "sync"
+
+type dispatchTask struct {
+	request *Request
+	message []byte
+}
+
+// clientTasks is the serial queue of a client. It sits in the ready list
+// of the pool at most once, so a single worker handles the client at a
+// time and its messages keep the order they were read in.
+type clientTasks struct {
+	client    *client
+	queue     chan dispatchTask
+	scheduled bool
+}
+
+// workersPoolManager runs handlers on a fixed set of workers shared by
+// every client. A worker takes a ready client, handles one of its messages
+// and puts the client back at the end of the ready list while it has more,
+// so a long handler holds up only its own client. A full client queue
+// blocks the reading of that client until its messages are handled.
+type workersPoolManager struct {
+	ready     []*clientTasks
+	queueSize int
+	handle    func(client *client, request *Request, message []byte)
+	closed    bool
+	closing   chan struct{}
+	mx        *sync.Mutex
+	cond      *sync.Cond
+	workers   *sync.WaitGroup
+}
+
+func newWorkersPoolManager(workers int, queueSize int, handle func(client *client, request *Request, message []byte)) *workersPoolManager {
+	this := new(workersPoolManager)
+	this.ready = make([]*clientTasks, 0)
+	this.queueSize = queueSize
+	this.handle = handle
+	this.closing = make(chan struct{})
+	this.mx = new(sync.Mutex)
+	this.cond = sync.NewCond(this.mx)
+	this.workers = new(sync.WaitGroup)
+	this.workers.Add(workers)
+	for index := 0; index < workers; index++ {
+		go this.work()
+	}
+	return this
+}
+
+func (this *workersPoolManager) newClientTasks(client *client) *clientTasks {
+	return &clientTasks{
+		client: client,
+		queue:  make(chan dispatchTask, this.queueSize),
+	}
+}
+
+func (this *workersPoolManager) dispatch(tasks *clientTasks, request *Request, message []byte) {
+	select {
+	case tasks.queue <- dispatchTask{
+		request: request,
+		message: message,
+	}:
+	case <-this.closing:
+		return
+	}
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	if !tasks.scheduled && !this.closed {
+		tasks.scheduled = true
+		this.ready = append(this.ready, tasks)
+		this.cond.Signal()
+	}
+}
+
+func (this *workersPoolManager) work() {
+	defer this.workers.Done()
+	for {
+		this.mx.Lock()
+		for len(this.ready) == 0 && !this.closed {
+			this.cond.Wait()
+		}
+		if this.closed {
+			this.mx.Unlock()
+			return
+		}
+		tasks := this.ready[0]
+		this.ready[0] = nil
+		this.ready = this.ready[1:]
+		this.mx.Unlock()
+
+		select {
+		case task := <-tasks.queue:
+			this.handle(tasks.client, task.request, task.message)
+		default:
+		}
+
+		this.mx.Lock()
+		if len(tasks.queue) > 0 && !this.closed {
+			this.ready = append(this.ready, tasks)
+			this.cond.Signal()
+		} else {
+			tasks.scheduled = false
+		}
+		this.mx.Unlock()
+	}
+}
+
+// close stops the workers once their current handlers return, the queued
+// messages are dropped.
+func (this *workersPoolManager) close() {
+	this.mx.Lock()
+	if this.closed {
+		this.mx.Unlock()
+		return
+	}
+	this.closed = true
+	close(this.closing)
+	this.cond.Broadcast()
+	this.mx.Unlock()
+	this.workers.Wait()
+}
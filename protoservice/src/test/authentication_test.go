@@ -0,0 +1,171 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"protoservice/src/streaming"
+	"strings"
+	"testing"
+	"time"
+)
+
+func connectFakeClientWithHeader(t *testing.T, fakeServer *FakeServer, header http.Header) (*FakeClient, error) {
+	u, err := url.Parse(fakeServer.TestServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeClient := NewFakeClient(u)
+	fakeClient.Header = header
+	return fakeClient, fakeClient.ConnectWithServerByWS("/ws")
+}
+
+func bearerHeader(token string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+// signJWT issues an HS256 token for the claims with the key kid.
+func signJWT(kid string, key []byte, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func TestUpgradeWithStaticToken(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeServer.WebsocketEngine.SetAuthenticator(streaming.NewStaticTokenAuthenticator(map[string]streaming.Principal{
+		"secret-token": {Subject: "alice", Roles: []string{"uploader"}},
+	}))
+
+	if _, err := connectFakeClientWithHeader(t, fakeServer, nil); err == nil || err.Error() != "401 Unauthorized" {
+		t.Fatalf("client without a token is upgraded: %v", err)
+	}
+	if _, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("wrong-token")); err == nil || err.Error() != "401 Unauthorized" {
+		t.Fatalf("client with a wrong token is upgraded: %v", err)
+	}
+	fakeClient, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("secret-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fakeClient.Close()
+	handshake, err := fakeClient.OpenSession("owned.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fakeClient.SendFileFrame(handshake.GetSessionUuid(), []byte("data"), true); err != nil {
+		t.Fatal(err)
+	}
+	record, err := fakeServer.Catalog.Get(handshake.GetSessionUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Owner != "alice" {
+		t.Fatalf("file isn't owned by the principal: %q", record.Owner)
+	}
+}
+
+func TestUpgradeWithJWT(t *testing.T) {
+	key := []byte("first signing key")
+	authenticator := streaming.NewJWTAuthenticator(map[string][]byte{
+		"key-1": key,
+	})
+	authenticator.Issuer = "protoservice"
+	fakeServer := newFakeServer(t)
+	fakeServer.WebsocketEngine.SetAuthenticator(authenticator)
+
+	token, err := signJWT("key-1", key, map[string]interface{}{
+		"sub":   "bob",
+		"iss":   "protoservice",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": []string{"admin"},
+		"scope": "files:read files:write",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := authenticator.Verify(token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "bob" || !principal.HasRole("admin") || !principal.HasScope("files:write") {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	if _, err := authenticator.Verify(token, time.Now().Add(2*time.Minute)); err != streaming.ErrorCredentialsAreExpired {
+		t.Fatalf("expired token is accepted: %v", err)
+	}
+	signatureAt := strings.LastIndex(token, ".") + 1
+	forged := token[:signatureAt] + "A" + token[signatureAt+1:]
+	if token[signatureAt] == 'A' {
+		forged = token[:signatureAt] + "B" + token[signatureAt+1:]
+	}
+	if _, err := authenticator.Verify(forged, time.Now()); err != streaming.ErrorCredentialsArentValid {
+		t.Fatalf("token with a forged signature is accepted: %v", err)
+	}
+	foreign, err := signJWT("key-1", key, map[string]interface{}{"sub": "bob", "iss": "somebody"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticator.Verify(foreign, time.Now()); err != streaming.ErrorCredentialsArentValid {
+		t.Fatalf("token of another issuer is accepted: %v", err)
+	}
+
+	fakeClient, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeClient.Close()
+	if _, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader(foreign)); err == nil {
+		t.Fatal("client with an invalid token is upgraded")
+	}
+}
+
+func TestCertificateAuthenticatorInChain(t *testing.T) {
+	authenticator := streaming.NewChainAuthenticator(
+		streaming.NewStaticTokenAuthenticator(map[string]streaming.Principal{"token": {Subject: "service"}}),
+		streaming.NewCertificateAuthenticator(),
+	)
+	certificate := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "device-42", OrganizationalUnit: []string{"devices"}},
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if _, err := authenticator.Authenticate(request); err != streaming.ErrorCredentialsAreMissing {
+		t.Fatalf("request without credentials is authenticated: %v", err)
+	}
+	request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}
+	if _, err := authenticator.Authenticate(request); err != streaming.ErrorCredentialsArentValid {
+		t.Fatalf("unverified certificate is accepted: %v", err)
+	}
+	request.TLS.VerifiedChains = [][]*x509.Certificate{{certificate}}
+	principal, err := authenticator.Authenticate(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "device-42" || principal.Method != "mtls" || !principal.HasRole("devices") {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	request.Header.Set("Authorization", "Bearer token")
+	principal, err = authenticator.Authenticate(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "service" {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+	"time"
+)
+
+func TestBroadcastToClientsAndGroups(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	engine := fakeServer.WebsocketEngine
+	clients := make([]*FakeClient, 3)
+	addresses := make([]streaming.RemoteAddress, 3)
+	for index := range clients {
+		clients[index] = newConnectedFakeClient(t, fakeServer)
+		defer clients[index].Close()
+		addresses[index] = streaming.RemoteAddress(clients[index].connection.LocalAddr().String())
+	}
+	waitPoolLength(t, engine, len(clients))
+
+	delivered, err := engine.Broadcast(&fileservice.FileInfoResponce{FileId: "everybody"})
+	if err != nil || delivered != len(clients) {
+		t.Fatalf("unexpected broadcast to all: %d %v", delivered, err)
+	}
+	for _, fakeClient := range clients {
+		message := new(fileservice.FileInfoResponce)
+		if _, err := fakeClient.ReceiveBroadcast(message, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if message.GetFileId() != "everybody" {
+			t.Fatalf("unexpected broadcast: %v", message)
+		}
+	}
+
+	delivered, err = engine.BroadcastFunc(func(client streaming.ClientInfo) bool {
+		return client.RemoteAddress == addresses[0]
+	}, &fileservice.FileInfoResponce{FileId: "first"})
+	if err != nil || delivered != 1 {
+		t.Fatalf("unexpected broadcast by predicate: %d %v", delivered, err)
+	}
+
+	for _, address := range addresses[1:] {
+		if err := engine.AddToGroup("watchers", address); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.AddToGroup("watchers", "127.0.0.1:1"); err == nil {
+		t.Fatal("unknown client is added to the group")
+	}
+	delivered, err = engine.BroadcastGroup("watchers", &fileservice.FileInfoResponce{FileId: "group"})
+	if err != nil || delivered != 2 {
+		t.Fatalf("unexpected group broadcast: %d %v", delivered, err)
+	}
+	for index, fakeClient := range clients {
+		expected := "group"
+		if index == 0 {
+			expected = "first"
+		}
+		message := new(fileservice.FileInfoResponce)
+		group, err := fakeClient.ReceiveBroadcast(message, 2*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.GetFileId() != expected || (expected == "group" && group != "watchers") {
+			t.Fatalf("client %d received an unexpected broadcast: %v %q", index, message, group)
+		}
+	}
+}
+
+func TestSlowClientDoesntStallBroadcast(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		SendQueueSize:   2,
+	}))
+	slowClient := newConnectedFakeClient(t, fakeServer)
+	defer slowClient.Close()
+	waitPoolLength(t, fakeServer.WebsocketEngine, 1)
+
+	// the slow client never reads, so its socket buffers and then its send
+	// queue fill up
+	message := &fileservice.FileInfoResponce{FileName: string(bytes.Repeat([]byte("x"), 256*1024))}
+	started := time.Now()
+	for attempt := 0; attempt < 200; attempt++ {
+		if _, err := fakeServer.WebsocketEngine.Broadcast(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("broadcast is stalled by the slow client: %s", elapsed)
+	}
+	if metrics := fakeServer.WebsocketEngine.Metrics(); metrics.DroppedMessages == 0 {
+		t.Fatalf("messages for the slow client aren't dropped: %+v", metrics)
+	}
+}
+
+func waitPoolLength(t *testing.T, engine *streaming.Engine, length int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		count := 0
+		for range engine.PoolClients.Iterate() {
+			count++
+		}
+		if count == length {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pool has %d clients instead of %d", count, length)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAcceptedMessagesAreWrittenBeforeClose(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		SendQueueSize:   1024,
+	}))
+	engine := fakeServer.WebsocketEngine
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	waitPoolLength(t, engine, 1)
+	clientRemoteAddress := streaming.RemoteAddress(fakeClient.connection.LocalAddr().String())
+
+	received := make(chan int, 1)
+	go func() {
+		count := 0
+		for {
+			if _, err := fakeClient.Receive(); err != nil {
+				received <- count
+				return
+			}
+			count++
+		}
+	}()
+	accepted := 0
+	for attempt := 0; attempt < 500; attempt++ {
+		if attempt == 250 {
+			go engine.CloseConnectionClient(clientRemoteAddress)
+		}
+		queued, err := engine.Broadcast(&fileservice.FileInfoResponce{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		accepted += queued
+	}
+	select {
+	case count := <-received:
+		if count != accepted {
+			t.Fatalf("%d messages are accepted, %d are written", accepted, count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection isn't closed")
+	}
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func TestFinalizedUploadIsRecordedInCatalog(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	file := []byte("catalogued file")
+	fileID := uploadFile(t, fakeServer, "catalogued.txt", file)
+
+	record, err := fakeServer.Catalog.Get(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(file)
+	if record.Name != "catalogued.txt" ||
+		record.Size != int64(len(file)) ||
+		record.ContentType != "application/octet-stream" ||
+		record.Checksum != hex.EncodeToString(digest[:]) ||
+		record.SessionUUID != fileID ||
+		record.UploaderAddress == "" ||
+		record.UploadedAt.Before(record.CreatedAt) {
+		t.Fatalf("unexpected catalog record: %+v", record)
+	}
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	info, err := fakeClient.FileInfo(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GetFileName() != record.Name || info.GetSha256() != record.Checksum || !info.GetUploadedAt().AsTime().Equal(record.UploadedAt) {
+		t.Fatalf("unexpected file info: %v", info)
+	}
+
+	response, _ := doHttpRequest(t, http.MethodDelete, fakeServer.TestServer.URL+"/files/"+fileID, nil, nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected delete status %d", response.StatusCode)
+	}
+	if _, err := fakeServer.Catalog.Get(fileID); err != fileservice.ErrorRecordIsntExist {
+		t.Fatalf("deleted file is still in catalog: %v", err)
+	}
+	if _, err := fakeClient.FileInfo(fileID); err == nil || err.Error() != fileservice.ErrorFileIsntExist.Error() {
+		t.Fatalf("unexpected info error for deleted file: %v", err)
+	}
+}
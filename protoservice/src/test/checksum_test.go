@@ -0,0 +1,76 @@
+package test
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+	"protoservice/src/fileservice"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCorruptedFrameIsRejected(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	file := []byte("checksummed file")
+	digest := sha256.Sum256(file)
+	handshake, err := fakeClient.Handshake(&fileservice.HandshakeRequest{
+		FileName: "checksummed.txt",
+		Sha256:   digest[:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := crc32.Checksum(file, crc32.MakeTable(crc32.Castagnoli))
+	_, err = fakeClient.SendFileFrameRequest(&fileservice.FileStreamingRequest{
+		SessionUuid:    handshake.GetSessionUuid(),
+		StreamingFrame: []byte("corrupted frame!"),
+		Offset:         proto.Uint64(0),
+		Crc32C:         proto.Uint32(checksum),
+	})
+	if err == nil || err.Error() != fileservice.ErrorFrameChecksumMismatch.Error() {
+		t.Fatalf("corrupted frame is accepted: %v", err)
+	}
+	responce, err := fakeClient.SendFileFrameRequest(&fileservice.FileStreamingRequest{
+		SessionUuid:    handshake.GetSessionUuid(),
+		StreamingFrame: file,
+		LastFrame:      true,
+		Offset:         proto.Uint64(0),
+		Crc32C:         proto.Uint32(checksum),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetCommittedBytes() != uint64(len(file)) {
+		t.Fatalf("unexpected committed bytes: %d", responce.GetCommittedBytes())
+	}
+	if _, err := storage.Stat(handshake.GetSessionUuid() + "/checksummed.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileWithWrongDigestIsntStored(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	digest := sha256.Sum256([]byte("another file"))
+	handshake, err := fakeClient.Handshake(&fileservice.HandshakeRequest{
+		FileName: "tampered.txt",
+		Sha256:   digest[:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fakeClient.SendFile(handshake.GetSessionUuid(), []byte("tampered file"), 4)
+	if err == nil || err.Error() != fileservice.ErrorFileChecksumMismatch.Error() {
+		t.Fatalf("file with a wrong digest is accepted: %v", err)
+	}
+	if _, err := storage.Stat(handshake.GetSessionUuid() + "/tampered.txt"); err != fileservice.ErrorFileIsntExist {
+		t.Fatalf("file with a wrong digest is stored: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package test
+
+import (
+	"protoservice/src/streaming"
+	"testing"
+	"time"
+)
+
+func TestConnectionHooksAreCalled(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	connected := make(chan streaming.RemoteAddress, 1)
+	disconnected := make(chan streaming.RemoteAddress, 1)
+	fakeServer.WebsocketEngine.OnConnect(func(clientRemoteAddress streaming.RemoteAddress) {
+		connected <- clientRemoteAddress
+	})
+	fakeServer.WebsocketEngine.OnDisconnect(func(clientRemoteAddress streaming.RemoteAddress) {
+		disconnected <- clientRemoteAddress
+	})
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	clientRemoteAddress := streaming.RemoteAddress(fakeClient.connection.LocalAddr().String())
+	select {
+	case address := <-connected:
+		if address != clientRemoteAddress {
+			t.Fatalf("unexpected connected client %s", address)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connect hook isn't called")
+	}
+	fakeClient.Close()
+	select {
+	case address := <-disconnected:
+		if address != clientRemoteAddress {
+			t.Fatalf("unexpected disconnected client %s", address)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("disconnect hook isn't called")
+	}
+	if _, err := fakeServer.WebsocketEngine.PoolClients.Get(clientRemoteAddress); err == nil {
+		t.Fatal("disconnected client is still in the pool")
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestContextCarriesMetadata(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	metadata := make(chan map[string]string, 1)
+	fakeServer.WebsocketEngine.Handle("/metadata", func(context *streaming.Context) {
+		metadata <- context.Metadata
+		context.Reply(&fileservice.FileInfoResponce{})
+	})
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	fakeClient.Metadata = map[string]string{"trace-id": "trace-1", "content-type": "application/x-protobuf"}
+
+	if _, err := fakeClient.Request("/metadata", &fileservice.FileInfoRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	received := <-metadata
+	if received["trace-id"] != "trace-1" || received["content-type"] != "application/x-protobuf" {
+		t.Fatalf("unexpected metadata: %v", received)
+	}
+}
+
+func TestBindValidatesFrame(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	if _, err := fakeClient.FileInfo(""); err == nil || err.Error() != fileservice.ErrorFileIdIsntValid.Error() {
+		t.Fatalf("request without a file id is accepted: %v", err)
+	}
+	message, err := proto.Marshal(&streaming.Request{
+		Uri:       "/file/info",
+		Frame:     []byte{0xff, 0xff, 0xff},
+		RequestId: "broken",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fakeClient.connection.WriteMessage(websocket.BinaryMessage, message); err != nil {
+		t.Fatal(err)
+	}
+	responce, err := fakeClient.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetRequestId() != "broken" || responce.GetError() != streaming.ErrorFrameIsntUnmarshal.Error() {
+		t.Fatalf("unexpected responce to a broken frame: %v", responce)
+	}
+}
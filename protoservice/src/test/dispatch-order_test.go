@@ -0,0 +1,120 @@
+package test
+
+import (
+	"math/rand"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessagesOfClientAreHandledInOrder(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	mx := new(sync.Mutex)
+	handled := make(map[streaming.RemoteAddress][]int)
+	fakeServer.WebsocketEngine.Handle("/sequence", func(context *streaming.Context) {
+		request := new(fileservice.FileInfoRequest)
+		if err := proto.Unmarshal(context.Frame, request); err != nil {
+			context.ReplyError(err)
+			return
+		}
+		sequence, _ := strconv.Atoi(request.GetFileId())
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+		mx.Lock()
+		handled[context.ClientRemoteAddress] = append(handled[context.ClientRemoteAddress], sequence)
+		mx.Unlock()
+		context.Reply(&fileservice.FileInfoResponce{FileId: request.GetFileId()})
+	})
+
+	const messages = 50
+	clients := make([]*FakeClient, 3)
+	for index := range clients {
+		clients[index] = newConnectedFakeClient(t, fakeServer)
+		defer clients[index].Close()
+	}
+	wg := new(sync.WaitGroup)
+	for _, fakeClient := range clients {
+		wg.Add(1)
+		go func(fakeClient *FakeClient) {
+			defer wg.Done()
+			for sequence := 0; sequence < messages; sequence++ {
+				if _, err := fakeClient.Send("/sequence", &fileservice.FileInfoRequest{FileId: strconv.Itoa(sequence)}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			for sequence := 0; sequence < messages; sequence++ {
+				if _, err := fakeClient.Receive(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(fakeClient)
+	}
+	wg.Wait()
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(handled) != len(clients) {
+		t.Fatalf("unexpected number of clients: %d", len(handled))
+	}
+	for clientRemoteAddress, sequences := range handled {
+		if len(sequences) != messages {
+			t.Fatalf("client [%s] has %d handled messages", clientRemoteAddress, len(sequences))
+		}
+		for index, sequence := range sequences {
+			if sequence != index {
+				t.Fatalf("messages of the client [%s] are handled out of order: %v", clientRemoteAddress, sequences)
+			}
+		}
+	}
+}
+
+func TestLongHandlerDoesntHoldUpOtherClients(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		Workers:         2,
+	}))
+	fakeServer.WebsocketEngine.Handle("/ping", func(context *streaming.Context) {
+		context.Reply(&fileservice.FileInfoResponce{})
+	})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fakeServer.WebsocketEngine.Handle("/block", func(context *streaming.Context) {
+		close(started)
+		<-release
+		context.Reply(&fileservice.FileInfoResponce{})
+	})
+	blocked := newConnectedFakeClient(t, fakeServer)
+	defer blocked.Close()
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	defer close(release)
+
+	if _, err := blocked.Send("/block", &fileservice.FileInfoRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	handled := make(chan error, 1)
+	go func() {
+		for attempt := 0; attempt < 10; attempt++ {
+			if _, err := fakeClient.Request("/ping", &fileservice.FileInfoRequest{}); err != nil {
+				handled <- err
+				return
+			}
+		}
+		handled <- nil
+	}()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("messages of a client wait for the long handler of another client")
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"bytes"
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func uploadFile(t *testing.T, fakeServer *FakeServer, fileName string, file []byte) string {
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	handshake, err := fakeClient.OpenSession(fileName, uint64(len(file)), "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fakeClient.SendFile(handshake.GetSessionUuid(), file, 1000); err != nil {
+		t.Fatal(err)
+	}
+	return handshake.GetSessionUuid()
+}
+
+func TestFileIsDownloadedInFrames(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	file := bytes.Repeat([]byte("abcdefghij"), 1000)
+	fileID := uploadFile(t, fakeServer, "letters.txt", file)
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	frames, err := fakeClient.Download(fileID, 500, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("unexpected number of frames: %d", len(frames))
+	}
+	downloaded := bytes.NewBuffer(nil)
+	for _, frame := range frames {
+		if frame.GetOffset() != uint64(500+downloaded.Len()) {
+			t.Fatalf("unexpected frame offset: %d", frame.GetOffset())
+		}
+		downloaded.Write(frame.GetStreamingFrame())
+	}
+	if !bytes.Equal(downloaded.Bytes(), file[500:]) {
+		t.Fatal("downloaded file differs from the uploaded one")
+	}
+	if frames[0].GetFileName() != "letters.txt" || frames[0].GetFileSize() != uint64(len(file)) {
+		t.Fatalf("unexpected file metadata: %v", frames[0])
+	}
+
+	if _, err := fakeClient.Download(fileID, uint64(len(file)+1), 0); err == nil || err.Error() != fileservice.ErrorOffsetIsOutOfRange.Error() {
+		t.Fatalf("offset out of range is accepted: %v", err)
+	}
+	if _, err := fakeClient.Download("../secret", 0, 0); err == nil || err.Error() != fileservice.ErrorFileIdIsntValid.Error() {
+		t.Fatalf("invalid file id is accepted: %v", err)
+	}
+}
+
+func TestTruncatedFileDownloadFails(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+	file := bytes.Repeat([]byte("abcdefghij"), 1000)
+	fileID := uploadFile(t, fakeServer, "letters.txt", file)
+	record, err := fakeServer.Catalog.Get(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := storage.Create(record.StorageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(file[:5000])
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	if _, err := fakeClient.Download(fileID, 0, 4096); err == nil || err.Error() != fileservice.ErrorFileIsTruncated.Error() {
+		t.Fatalf("truncated file is downloaded: %v", err)
+	}
+}
+
+func TestDownloadStopsAtCatalogedSize(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+	file := bytes.Repeat([]byte("abcdefghij"), 1000)
+	fileID := uploadFile(t, fakeServer, "letters.txt", file)
+	record, err := fakeServer.Catalog.Get(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := storage.Create(record.StorageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(append(file, []byte("trailing bytes")...))
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	frames, err := fakeClient.Download(fileID, 0, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded := bytes.NewBuffer(nil)
+	for _, frame := range frames {
+		downloaded.Write(frame.GetStreamingFrame())
+	}
+	if !bytes.Equal(downloaded.Bytes(), file) {
+		t.Fatalf("downloaded %d bytes instead of %d", downloaded.Len(), len(file))
+	}
+}
@@ -1,13 +1,12 @@
 package test
 
 import (
-	"io/ioutil"
-	"log"
+	"errors"
 	"net/http"
-	"net/http/httptest"
 	"net/url"
 	"protoservice/src/fileservice"
 	"protoservice/src/streaming"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,159 +14,320 @@ import (
 )
 
 type FakeClient struct {
-	TestServer *httptest.Server
+	Header     http.Header
+	Metadata   map[string]string
 	backend    *url.URL
 	connection *websocket.Conn
-	done       chan struct{}
+	requestID  int
 }
 
 func NewFakeClient(backend *url.URL) *FakeClient {
 	this := new(FakeClient)
-	this.TestServer = httptest.NewServer(this)
 	this.backend = backend
-	this.done = make(chan struct{})
 	return this
 }
 
-func (this *FakeClient) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	err := this.ConnectWithServerByWS("/ws")
-	if err != nil {
-		log.Println(err)
-	}
-}
-
 func (this *FakeClient) ConnectWithServerByWS(endpoint string) error {
 	this.backend.Scheme = "ws"
 	this.backend.Path = endpoint
-	connection, _, err := websocket.DefaultDialer.Dial(this.backend.String(), nil)
+	connection, responce, err := websocket.DefaultDialer.Dial(this.backend.String(), this.Header)
 	if err != nil {
+		if responce != nil {
+			return errors.New(responce.Status)
+		}
 		return err
 	}
 	this.connection = connection
-	go this.handleWS()
 	return nil
 }
 
-func (this *FakeClient) handleWS() {
-
-	// /Users/vlad/Job/sber/prototest/protoservice/storage/test
+func (this *FakeClient) Close() error {
+	return this.connection.Close()
+}
 
-	requestHandshake := &fileservice.HandshakeRequest{
-		RemoteAddress: this.connection.LocalAddr().String(),
-	}
-	reqbts, err := proto.Marshal(requestHandshake)
+func (this *FakeClient) Send(uri string, request proto.Message) (string, error) {
+	frame, err := proto.Marshal(request)
 	if err != nil {
-		log.Println(err)
-		return
-	}
-	request := &streaming.Request{
-		Uri:   "/session/open",
-		Frame: reqbts,
+		return "", err
 	}
-	readyMessage, err := proto.Marshal(request)
+	this.requestID++
+	requestID := strconv.Itoa(this.requestID)
+	readyMessage, err := proto.Marshal(&streaming.Request{
+		Uri:       uri,
+		Frame:     frame,
+		RequestId: requestID,
+		Metadata:  this.Metadata,
+	})
 	if err != nil {
-		log.Println(err)
-		return
+		return "", err
 	}
 	err = this.connection.WriteMessage(
 		websocket.BinaryMessage,
 		readyMessage,
 	)
 	if err != nil {
-		log.Println(err)
-		return
+		return "", err
 	}
-	//
-	_, respbts, err := this.connection.ReadMessage()
+	return requestID, nil
+}
+
+func (this *FakeClient) Receive() (*streaming.Responce, error) {
+	_, message, err := this.connection.ReadMessage()
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, err
 	}
-	if err != nil {
-		log.Println(err)
-		return
+	responce := new(streaming.Responce)
+	if err := proto.Unmarshal(message, responce); err != nil {
+		return nil, err
 	}
-	responseHandshake := new(fileservice.HandshakeResponce)
-	err = proto.Unmarshal(respbts, responseHandshake)
+	return responce, nil
+}
+
+func (this *FakeClient) Request(uri string, request proto.Message) (*streaming.Responce, error) {
+	requestID, err := this.Send(uri, request)
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, err
+	}
+	for {
+		responce, err := this.Receive()
+		if err != nil {
+			return nil, err
+		}
+		if responce.GetRequestId() == requestID {
+			return responce, nil
+		}
 	}
-	log.Println("Start session from client ", this.connection.LocalAddr().String(), " with uuid ", responseHandshake.GetSessionUuid())
+}
+
+func (this *FakeClient) Subscribe(topic string) error {
+	return this.requestTopic(streaming.SubscribeURI, topic)
+}
 
-	file, err := ioutil.ReadFile("/Users/vlad/Job/sber/prototest/protoservice/storage/test")
+func (this *FakeClient) Unsubscribe(topic string) error {
+	return this.requestTopic(streaming.UnsubscribeURI, topic)
+}
+
+func (this *FakeClient) requestTopic(uri streaming.URI, topic string) error {
+	responce, err := this.Request(string(uri), &streaming.TopicSubscription{
+		Topic: topic,
+	})
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
+	if responce.GetError() != "" {
+		return errors.New(responce.GetError())
+	}
+	return nil
+}
 
-	defer func() {
-		err := this.connection.Close()
+// ReceivePublication waits for a message pushed to topic and unmarshals it
+// into message, the other messages are skipped.
+func (this *FakeClient) ReceivePublication(topic string, message proto.Message, timeout time.Duration) error {
+	this.connection.SetReadDeadline(time.Now().Add(timeout))
+	defer this.connection.SetReadDeadline(time.Time{})
+	for {
+		responce, err := this.Receive()
 		if err != nil {
-			log.Println("Fnish :", err)
+			return err
 		}
-	}()
-	go func() {
-		defer close(this.done)
-		for {
-			_, _, err := this.connection.ReadMessage()
-			if err != nil {
-				log.Println(err)
-				return
-			}
+		if responce.GetUri() == string(streaming.PublishURI) && responce.GetTopic() == topic {
+			return proto.Unmarshal(responce.GetFrame(), message)
 		}
-	}()
+	}
+}
 
-	countSlices := int(len(file) / 5)
-	from, to := int(0), countSlices
-	timer := time.NewTicker(2 * time.Second)
+// ReceiveBroadcast waits for a broadcast message, unmarshals it into
+// message and returns the group it was sent to.
+func (this *FakeClient) ReceiveBroadcast(message proto.Message, timeout time.Duration) (string, error) {
+	this.connection.SetReadDeadline(time.Now().Add(timeout))
+	defer this.connection.SetReadDeadline(time.Time{})
 	for {
-		select {
-		case <-this.done:
-			return
-		case <-timer.C:
-			var (
-				bts       []byte
-				lastframe = false
-			)
-			if to < len(file) {
-				bts = file[from:to]
-			} else {
-				bts = file[from : len(file)-1]
-				lastframe = true
-			}
-			frame := &fileservice.FileStreamingRequest{
-				LastFrame:      lastframe,
-				StreamingFrame: bts,
-				SessionUuid:    responseHandshake.GetSessionUuid(),
-			}
-			if message, err := proto.Marshal(frame); err != nil {
-				log.Println(err)
-				return
-			} else {
-				request := &streaming.Request{
-					Uri:   "/send/file",
-					Frame: message,
-				}
-				readyMessage, err := proto.Marshal(request)
-				if err != nil {
-					log.Println(err)
-					return
-				}
-				err = this.connection.WriteMessage(
-					websocket.BinaryMessage,
-					readyMessage,
-				)
-				if err != nil {
-					log.Println(err)
-					return
-				}
-			}
-			from = from + to
-			to = to + 1
-			if lastframe {
-				return
-			}
+		responce, err := this.Receive()
+		if err != nil {
+			return "", err
 		}
+		if responce.GetUri() == string(streaming.BroadcastURI) {
+			return responce.GetGroup(), proto.Unmarshal(responce.GetFrame(), message)
+		}
+	}
+}
+
+func (this *FakeClient) Handshake(request *fileservice.HandshakeRequest) (*fileservice.HandshakeResponce, error) {
+	if request.RemoteAddress == "" {
+		request.RemoteAddress = this.connection.LocalAddr().String()
+	}
+	responce, err := this.Request("/session/open", request)
+	if err != nil {
+		return nil, err
+	}
+	if responce.GetError() != "" {
+		return nil, errors.New(responce.GetError())
+	}
+	responceHandshake := new(fileservice.HandshakeResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), responceHandshake); err != nil {
+		return nil, err
+	}
+	return responceHandshake, nil
+}
+
+func (this *FakeClient) OpenSession(fileName string, fileSize uint64, contentType string) (*fileservice.HandshakeResponce, error) {
+	return this.Handshake(&fileservice.HandshakeRequest{
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ContentType: contentType,
+	})
+}
+
+func (this *FakeClient) ResumeSession(sessionUUID, resumeToken string) (*fileservice.HandshakeResponce, error) {
+	return this.Handshake(&fileservice.HandshakeRequest{
+		SessionUuid: sessionUUID,
+		ResumeToken: resumeToken,
+	})
+}
+
+func (this *FakeClient) SessionStatus(sessionUUID, resumeToken string) (*fileservice.SessionStatusResponce, error) {
+	responce, err := this.Request("/session/status", &fileservice.SessionStatusRequest{
+		SessionUuid: sessionUUID,
+		ResumeToken: resumeToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if responce.GetError() != "" {
+		return nil, errors.New(responce.GetError())
+	}
+	responceStatus := new(fileservice.SessionStatusResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), responceStatus); err != nil {
+		return nil, err
+	}
+	return responceStatus, nil
+}
+
+func (this *FakeClient) SendFileFrame(sessionUUID string, frame []byte, lastFrame bool) (*fileservice.FileStreamingResponce, error) {
+	return this.SendFileFrameRequest(&fileservice.FileStreamingRequest{
+		SessionUuid:    sessionUUID,
+		LastFrame:      lastFrame,
+		StreamingFrame: frame,
+	})
+}
+
+func (this *FakeClient) SendFileFrameAt(sessionUUID string, offset uint64, frame []byte, lastFrame bool) (*fileservice.FileStreamingResponce, error) {
+	return this.SendFileFrameRequest(&fileservice.FileStreamingRequest{
+		SessionUuid:    sessionUUID,
+		LastFrame:      lastFrame,
+		StreamingFrame: frame,
+		Offset:         proto.Uint64(offset),
+	})
+}
+
+func (this *FakeClient) SendFileFrameRequest(request *fileservice.FileStreamingRequest) (*fileservice.FileStreamingResponce, error) {
+	responce, err := this.Request("/send/file", request)
+	if err != nil {
+		return nil, err
+	}
+	responceFrame := new(fileservice.FileStreamingResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), responceFrame); err != nil {
+		return nil, err
+	}
+	if responce.GetError() != "" {
+		return responceFrame, errors.New(responce.GetError())
+	}
+	return responceFrame, nil
+}
+
+func (this *FakeClient) SendFile(sessionUUID string, file []byte, frameSize int) error {
+	return this.SendFileFrom(sessionUUID, file, 0, frameSize)
+}
+
+func (this *FakeClient) SendFileFrom(sessionUUID string, file []byte, offset int, frameSize int) error {
+	for from := offset; ; from += frameSize {
+		to := from + frameSize
+		lastFrame := to >= len(file)
+		if lastFrame {
+			to = len(file)
+		}
+		if _, err := this.SendFileFrameAt(sessionUUID, uint64(from), file[from:to], lastFrame); err != nil {
+			return err
+		}
+		if lastFrame {
+			return nil
+		}
+	}
+}
+
+func (this *FakeClient) Download(fileID string, offset uint64, chunkSize uint32) ([]*fileservice.FileDownloadResponce, error) {
+	requestID, err := this.Send("/file/get", &fileservice.FileDownloadRequest{
+		FileId:    fileID,
+		Offset:    offset,
+		ChunkSize: chunkSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]*fileservice.FileDownloadResponce, 0)
+	for {
+		responce, err := this.Receive()
+		if err != nil {
+			return frames, err
+		}
+		if responce.GetRequestId() != requestID {
+			continue
+		}
+		if responce.GetError() != "" {
+			return frames, errors.New(responce.GetError())
+		}
+		frame := new(fileservice.FileDownloadResponce)
+		if err := proto.Unmarshal(responce.GetFrame(), frame); err != nil {
+			return frames, err
+		}
+		frames = append(frames, frame)
+		if frame.GetLastFrame() {
+			return frames, nil
+		}
+	}
+}
+
+func (this *FakeClient) FileInfo(fileID string) (*fileservice.FileInfoResponce, error) {
+	responce, err := this.Request("/file/info", &fileservice.FileInfoRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if responce.GetError() != "" {
+		return nil, errors.New(responce.GetError())
+	}
+	responceInfo := new(fileservice.FileInfoResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), responceInfo); err != nil {
+		return nil, err
+	}
+	return responceInfo, nil
+}
+
+func (this *FakeClient) DeleteFile(fileID string) error {
+	responce, err := this.Request("/file/delete", &fileservice.FileDeleteRequest{
+		FileId: fileID,
+	})
+	if err != nil {
+		return err
+	}
+	if responce.GetError() != "" {
+		return errors.New(responce.GetError())
+	}
+	return nil
+}
+
+func (this *FakeClient) ListFiles(request *fileservice.FileListRequest) (*fileservice.FileListResponce, error) {
+	responce, err := this.Request("/file/list", request)
+	if err != nil {
+		return nil, err
+	}
+	if responce.GetError() != "" {
+		return nil, errors.New(responce.GetError())
+	}
+	responceList := new(fileservice.FileListResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), responceList); err != nil {
+		return nil, err
 	}
+	return responceList, nil
 }
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func TestFilesArePagedAndFiltered(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	uploads := []struct {
+		name        string
+		contentType string
+		content     string
+	}{
+		{"report-1.txt", "text/plain", "1"},
+		{"report-2.txt", "text/plain", "22"},
+		{"image.png", "image/png", "333"},
+		{"report-3.csv", "text/csv", "4444"},
+		{"report-4.txt", "text/plain", "55555"},
+	}
+	for _, upload := range uploads {
+		response, body := doHttpRequest(t, http.MethodPut, fakeServer.TestServer.URL+"/files/"+upload.name, []byte(upload.content), http.Header{
+			"Content-Type": {upload.contentType},
+		})
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("unexpected upload status %d: %s", response.StatusCode, body)
+		}
+	}
+
+	names := make([]string, 0)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		response, body := doHttpRequest(t, http.MethodGet, fakeServer.TestServer.URL+"/files?name_prefix=report&limit=2&cursor="+cursor, nil, nil)
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected list status %d: %s", response.StatusCode, body)
+		}
+		page := struct {
+			Files []struct {
+				Name string `json:"name"`
+			} `json:"files"`
+			NextCursor string `json:"next_cursor"`
+		}{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Files) > 2 {
+			t.Fatalf("page exceeds the limit: %s", body)
+		}
+		for _, file := range page.Files {
+			names = append(names, file.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > len(uploads) {
+			t.Fatal("listing doesn't terminate")
+		}
+		cursor = page.NextCursor
+	}
+	expected := []string{"report-1.txt", "report-2.txt", "report-3.csv", "report-4.txt"}
+	if len(names) != len(expected) {
+		t.Fatalf("unexpected listing %v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("unexpected listing %v", names)
+		}
+	}
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	listed, err := fakeClient.ListFiles(&fileservice.FileListRequest{
+		ContentType: "text/plain",
+		MinSize:     2,
+		MaxSize:     4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed.GetFiles()) != 1 || listed.GetFiles()[0].GetFileName() != "report-2.txt" || listed.GetNextCursor() != "" {
+		t.Fatalf("unexpected filtered listing: %v", listed)
+	}
+	if _, err := fakeClient.ListFiles(&fileservice.FileListRequest{Cursor: "%%%"}); err == nil || err.Error() != fileservice.ErrorCursorIsntValid.Error() {
+		t.Fatalf("invalid cursor is accepted: %v", err)
+	}
+}
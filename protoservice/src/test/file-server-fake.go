@@ -3,25 +3,39 @@ package test
 import (
 	"net/http/httptest"
 	"protoservice/src/application"
+	"protoservice/src/fileservice"
 	"protoservice/src/streaming"
 )
 
 type FakeServer struct {
 	HttpEngine      *application.HttpEngine
 	WebsocketEngine *streaming.Engine
+	Catalog         fileservice.Catalog
 	TestServer      *httptest.Server
 }
 
-// /Users/vlad/Job/sber/prototest/protoservice/storage/fileservice
-func NewFakeServer() *FakeServer {
+func NewFakeServer(websocketEngine *streaming.Engine, storage fileservice.Storage, catalog fileservice.Catalog, sessionTimeouts fileservice.SessionTimeouts) (*FakeServer, error) {
 	this := new(FakeServer)
-	this.WebsocketEngine = streaming.NewEngine(5, 100)
-	this.HttpEngine = application.NewHttpEngine(
+	this.Catalog = catalog
+	this.WebsocketEngine = websocketEngine
+	httpEngine, err := application.NewHttpEngine(
 		this.WebsocketEngine,
 		"",
-		"/Users/vlad/Job/sber/prototest/protoservice",
-		"storage/fileservice",
+		storage,
+		catalog,
+		sessionTimeouts,
 	)
+	if err != nil {
+		return nil, err
+	}
+	this.HttpEngine = httpEngine
 	this.TestServer = httptest.NewServer(this.HttpEngine.HttpEngine)
-	return this
+	return this, nil
+}
+
+func (this *FakeServer) Close() {
+	this.WebsocketEngine.Close()
+	this.TestServer.Close()
+	this.HttpEngine.Close()
+	this.Catalog.Close()
 }
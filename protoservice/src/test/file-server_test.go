@@ -1,25 +1,230 @@
 package test
 
 import (
-	"net/http"
+	"bytes"
+	"io/ioutil"
 	"net/url"
+	"path/filepath"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
 	"testing"
 	"time"
 )
 
+type fakeServerOptions struct {
+	storage         fileservice.Storage
+	sessionTimeouts fileservice.SessionTimeouts
+	engineConfig    *streaming.Config
+	authenticator   streaming.Authenticator
+}
 
-func TestFileStreamingServerFlow(t *testing.T) {
-	fakeServer := NewFakeServer()
-	defer fakeServer.TestServer.Close()
+type fakeServerOption func(options *fakeServerOptions)
+
+func withStorage(storage fileservice.Storage) fakeServerOption {
+	return func(options *fakeServerOptions) {
+		options.storage = storage
+	}
+}
+
+func withSessionTimeouts(sessionTimeouts fileservice.SessionTimeouts) fakeServerOption {
+	return func(options *fakeServerOptions) {
+		options.sessionTimeouts = sessionTimeouts
+	}
+}
+
+func withEngineConfig(config streaming.Config) fakeServerOption {
+	return func(options *fakeServerOptions) {
+		options.engineConfig = &config
+	}
+}
+
+func withAuthenticator(authenticator streaming.Authenticator) fakeServerOption {
+	return func(options *fakeServerOptions) {
+		options.authenticator = authenticator
+	}
+}
+
+// newFakeServer starts a server with a memory storage, a bolt catalog in a
+// temporary directory and the default engine unless opts say otherwise.
+func newFakeServer(t *testing.T, opts ...fakeServerOption) *FakeServer {
+	options := &fakeServerOptions{
+		storage: fileservice.NewMemoryStorage(),
+		sessionTimeouts: fileservice.SessionTimeouts{
+			Idle:        time.Minute,
+			MaxLifetime: time.Hour,
+		},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	catalog, err := fileservice.NewBoltCatalog(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	websocketEngine := streaming.NewEngine(5, 100)
+	if options.engineConfig != nil {
+		websocketEngine = streaming.NewEngineWithConfig(*options.engineConfig)
+	}
+	if options.authenticator != nil {
+		websocketEngine.SetAuthenticator(options.authenticator)
+	}
+	fakeServer, err := NewFakeServer(websocketEngine, options.storage, catalog, options.sessionTimeouts)
+	if err != nil {
+		catalog.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(fakeServer.Close)
+	return fakeServer
+}
+
+func newConnectedFakeClient(t *testing.T, fakeServer *FakeServer) *FakeClient {
 	u, err := url.Parse(fakeServer.TestServer.URL)
 	if err != nil {
 		t.Fatal(err)
 	}
 	fakeClient := NewFakeClient(u)
-	_, err = http.Get(fakeClient.TestServer.URL)
+	if err := fakeClient.ConnectWithServerByWS("/ws"); err != nil {
+		t.Fatal(err)
+	}
+	return fakeClient
+}
+
+func TestFileStreamingServerFlow(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	handshake, err := fakeClient.OpenSession("frame.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handshake.GetSessionUuid() == "" {
+		t.Fatal("handshake responce doesn't contain session uuid")
+	}
+	responce, err := fakeClient.SendFileFrame(handshake.GetSessionUuid(), []byte("frame"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !responce.GetOk() {
+		t.Fatalf("file frame isn't accepted: %s", responce.GetError())
+	}
+}
+
+func TestUnknownSessionIsRejected(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	responce, err := fakeClient.SendFileFrame("00000000-0000-0000-0000-000000000000", []byte("frame"), false)
+	if err == nil {
+		t.Fatal("frame for unknown session is accepted")
+	}
+	if responce.GetOk() {
+		t.Fatal("frame responce for unknown session is ok")
+	}
+}
+
+func TestResponcesEchoRequestID(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	handshakeRequestID, err := fakeClient.Send("/session/open", &fileservice.HandshakeRequest{
+		RemoteAddress: fakeClient.connection.LocalAddr().String(),
+		FileName:      "file.txt",
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	unknownRequestID, err := fakeClient.Send("/unknown/uri", &fileservice.HandshakeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responces := make(map[string]*streaming.Responce)
+	for len(responces) < 2 {
+		responce, err := fakeClient.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		responces[responce.GetRequestId()] = responce
+	}
+	if responce := responces[handshakeRequestID]; responce == nil || responce.GetError() != "" || responce.GetUri() != "/session/open" {
+		t.Fatalf("unexpected handshake responce: %v", responce)
+	}
+	if responce := responces[unknownRequestID]; responce == nil || responce.GetError() != streaming.ErrorHandlerIsntExist.Error() {
+		t.Fatalf("unexpected responce for unknown uri: %v", responce)
+	}
+}
+
+func TestFileIsStreamedToStorage(t *testing.T) {
+	rootPath := t.TempDir()
+	fakeServer := newFakeServer(t, withStorage(fileservice.NewLocalStorage(rootPath, "storage/fileservice")))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
 
-	time.Sleep(10 * time.Minute)
+	file, err := ioutil.ReadFile("../../storage/test/test.jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshake, err := fakeClient.OpenSession("test.jpeg", uint64(len(file)), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fakeClient.SendFile(handshake.GetSessionUuid(), file, len(file)/5); err != nil {
+		t.Fatal(err)
+	}
+	storagePath := filepath.Join(rootPath, "storage", "fileservice", handshake.GetSessionUuid())
+	stored, err := ioutil.ReadFile(filepath.Join(storagePath, "test.jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, file) {
+		t.Fatal("stored file differs from the sent one")
+	}
+	if temporaryFiles, _ := filepath.Glob(filepath.Join(storagePath, "*.part")); len(temporaryFiles) != 0 {
+		t.Fatal("temporary file isn't removed after the last frame")
+	}
+}
+
+func TestUploadsAreStoredUnderSanitizedUniquePaths(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+
+	sessions := make([]string, 0)
+	for _, content := range []string{"first", "second"} {
+		fakeClient := newConnectedFakeClient(t, fakeServer)
+		handshake, err := fakeClient.OpenSession("../../etc/passwd", uint64(len(content)), "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if handshake.GetFileName() != "passwd" {
+			t.Fatalf("file name isn't sanitized: %s", handshake.GetFileName())
+		}
+		if err := fakeClient.SendFile(handshake.GetSessionUuid(), []byte(content), 3); err != nil {
+			t.Fatal(err)
+		}
+		fakeClient.Close()
+		sessions = append(sessions, handshake.GetSessionUuid())
+	}
+	for i, content := range []string{"first", "second"} {
+		info, err := storage.Stat(sessions[i] + "/passwd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len(content)) {
+			t.Fatalf("upload [%s] is overwritten", sessions[i])
+		}
+	}
+}
+
+func TestHandshakeWithInvalidFileNameIsRejected(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	// ".backup.part" has the form of the temporary files of LocalStorage
+	for _, fileName := range []string{"..", ".backup.part"} {
+		fakeClient := newConnectedFakeClient(t, fakeServer)
+		if _, err := fakeClient.OpenSession(fileName, 0, ""); err == nil || err.Error() != fileservice.ErrorFileNameIsntValid.Error() {
+			t.Fatalf("invalid file name [%s] is accepted: %v", fileName, err)
+		}
+		fakeClient.Close()
+	}
 }
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func doHttpRequest(t *testing.T, method, url string, body []byte, header http.Header) (*http.Response, []byte) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response, responseBody
+}
+
+func TestHttpFileLifecycle(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	file := []byte("0123456789abcdefghij")
+
+	response, body := doHttpRequest(t, http.MethodPut, fakeServer.TestServer.URL+"/files/report.txt", file, http.Header{
+		"Content-Type": {"text/plain"},
+	})
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected upload status %d: %s", response.StatusCode, body)
+	}
+	uploaded := struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}{}
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.Name != "report.txt" || uploaded.Size != int64(len(file)) {
+		t.Fatalf("unexpected upload responce: %s", body)
+	}
+	fileURL := fakeServer.TestServer.URL + "/files/" + uploaded.ID
+
+	response, body = doHttpRequest(t, http.MethodGet, fileURL, nil, nil)
+	if response.StatusCode != http.StatusOK || !bytes.Equal(body, file) {
+		t.Fatalf("unexpected download %d: %s", response.StatusCode, body)
+	}
+	response, body = doHttpRequest(t, http.MethodGet, fileURL, nil, http.Header{
+		"Range": {"bytes=10-14"},
+	})
+	if response.StatusCode != http.StatusPartialContent || string(body) != "abcde" {
+		t.Fatalf("unexpected range download %d: %s", response.StatusCode, body)
+	}
+	response, body = doHttpRequest(t, http.MethodHead, fileURL, nil, nil)
+	if response.StatusCode != http.StatusOK || len(body) != 0 || response.ContentLength != int64(len(file)) {
+		t.Fatalf("unexpected head responce %d, length %d", response.StatusCode, response.ContentLength)
+	}
+	response, _ = doHttpRequest(t, http.MethodDelete, fileURL, nil, nil)
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected delete status %d", response.StatusCode)
+	}
+	response, _ = doHttpRequest(t, http.MethodGet, fileURL, nil, nil)
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted file is still served with status %d", response.StatusCode)
+	}
+}
+
+func TestHttpUploadWithWrongChecksumIsRejected(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+
+	response, body := doHttpRequest(t, http.MethodPut, fakeServer.TestServer.URL+"/files/broken.bin", []byte("payload"), http.Header{
+		"X-Checksum-Sha256": {"0000000000000000000000000000000000000000000000000000000000000000"},
+	})
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d: %s", response.StatusCode, body)
+	}
+	if files, _ := storage.List(""); len(files) != 0 {
+		t.Fatalf("rejected upload is stored: %v", files)
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"errors"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	mx := new(sync.Mutex)
+	calls := make([]string, 0)
+	record := func(call string) {
+		mx.Lock()
+		defer mx.Unlock()
+		calls = append(calls, call)
+	}
+	// the outer middleware records its "after" call once the reply is
+	// already sent, so the test waits for it before checking the calls
+	completed := make(chan struct{}, 2)
+	fakeServer.WebsocketEngine.Use(func(context *streaming.Context) {
+		record("global:before:" + string(context.URI))
+		context.Next()
+		record("global:after:" + string(context.URI))
+		completed <- struct{}{}
+	})
+	fakeServer.WebsocketEngine.Handle("/echo", func(context *streaming.Context) {
+		record("handler")
+		context.Reply(&fileservice.FileInfoResponce{})
+	}, func(context *streaming.Context) {
+		record("route")
+	})
+	fakeServer.WebsocketEngine.Handle("/guarded", func(context *streaming.Context) {
+		record("guarded handler")
+	}, func(context *streaming.Context) {
+		context.AbortWithError(errors.New("Error: guarded"))
+	})
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	responce, err := fakeClient.Request("/echo", &fileservice.FileInfoRequest{})
+	if err != nil || responce.GetError() != "" {
+		t.Fatalf("unexpected responce: %v %v", responce, err)
+	}
+	responce, err = fakeClient.Request("/guarded", &fileservice.FileInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != "Error: guarded" {
+		t.Fatalf("aborted chain replied %q", responce.GetError())
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-completed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("middleware chain isn't completed")
+		}
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	expected := "global:before:/echo,route,handler,global:after:/echo,global:before:/guarded,global:after:/guarded"
+	if strings.Join(calls, ",") != expected {
+		t.Fatalf("unexpected middleware calls: %v", calls)
+	}
+}
+
+func TestOverlongChainsAreRejectedOnRegistration(t *testing.T) {
+	engine := streaming.NewEngine(5, 100)
+	noop := func(context *streaming.Context) {}
+	middleware := make([]streaming.Handler, 40)
+	for index := range middleware {
+		middleware[index] = noop
+	}
+	if err := engine.Handle("/long", noop, middleware...); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Use(middleware...); err != streaming.ErrorHandlersAreTooMany {
+		t.Fatalf("middleware making a route chain too long is added: %v", err)
+	}
+	if err := engine.Use(middleware[:10]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Handle("/longer", noop, append(middleware, middleware[:20]...)...); err != streaming.ErrorHandlersAreTooMany {
+		t.Fatalf("route with a too long chain is registered: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+)
+
+func withRoleTokens() fakeServerOption {
+	return withAuthenticator(streaming.NewStaticTokenAuthenticator(map[string]streaming.Principal{
+		"viewer-token":   {Subject: "viewer"},
+		"uploader-token": {Subject: "uploader", Roles: []string{"uploader"}},
+		"admin-token":    {Subject: "admin", Roles: []string{"admin"}},
+	}))
+}
+
+func TestHandlerPoliciesAreEnforced(t *testing.T) {
+	fakeServer := newFakeServer(t, withRoleTokens())
+
+	viewer, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("viewer-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewer.Close()
+	if _, err := viewer.OpenSession("denied.txt", 0, "text/plain"); err == nil || err.Error() != streaming.ErrorAccessIsDenied.Error() {
+		t.Fatalf("viewer opened an upload session: %v", err)
+	}
+
+	uploader, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("uploader-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uploader.Close()
+	handshake, err := uploader.OpenSession("allowed.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uploader.SendFileFrame(handshake.GetSessionUuid(), []byte("data"), true); err != nil {
+		t.Fatal(err)
+	}
+	fileID := handshake.GetSessionUuid()
+	// the connection is closed once the upload is completed
+	uploader, err = connectFakeClientWithHeader(t, fakeServer, bearerHeader("uploader-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uploader.Close()
+	// files without a policy stay readable by every authenticated client
+	if _, err := viewer.FileInfo(fileID); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploader.DeleteFile(fileID); err == nil || err.Error() != streaming.ErrorAccessIsDenied.Error() {
+		t.Fatalf("uploader deleted the file: %v", err)
+	}
+
+	admin, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("admin-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if err := admin.DeleteFile(fileID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.FileInfo(fileID); err == nil {
+		t.Fatal("deleted file is still available")
+	}
+}
+
+func TestHttpDeleteRequiresAdmin(t *testing.T) {
+	fakeServer := newFakeServer(t, withRoleTokens())
+	uploader, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("uploader-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uploader.Close()
+	handshake, err := uploader.OpenSession("http.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uploader.SendFileFrame(handshake.GetSessionUuid(), []byte("data"), true); err != nil {
+		t.Fatal(err)
+	}
+	url := fakeServer.TestServer.URL + "/files/" + handshake.GetSessionUuid()
+
+	if response, _ := doHttpRequest(t, http.MethodDelete, url, nil, nil); response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unexpected status without credentials: %d", response.StatusCode)
+	}
+	if response, _ := doHttpRequest(t, http.MethodDelete, url, nil, bearerHeader("uploader-token")); response.StatusCode != http.StatusForbidden {
+		t.Fatalf("unexpected status for the uploader: %d", response.StatusCode)
+	}
+	if response, _ := doHttpRequest(t, http.MethodDelete, url, nil, bearerHeader("admin-token")); response.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status for the admin: %d", response.StatusCode)
+	}
+}
+
+func TestHttpReadsRequireAuthentication(t *testing.T) {
+	fakeServer := newFakeServer(t, withRoleTokens())
+	uploader, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("uploader-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uploader.Close()
+	handshake, err := uploader.OpenSession("http.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uploader.SendFileFrame(handshake.GetSessionUuid(), []byte("data"), true); err != nil {
+		t.Fatal(err)
+	}
+	url := fakeServer.TestServer.URL + "/files/" + handshake.GetSessionUuid()
+
+	for _, request := range []struct{ method, url string }{
+		{http.MethodGet, fakeServer.TestServer.URL + "/files"},
+		{http.MethodGet, url},
+		{http.MethodHead, url},
+	} {
+		if response, _ := doHttpRequest(t, request.method, request.url, nil, nil); response.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("unexpected status of %s %s without credentials: %d", request.method, request.url, response.StatusCode)
+		}
+	}
+	if response, body := doHttpRequest(t, http.MethodGet, url, nil, bearerHeader("uploader-token")); response.StatusCode != http.StatusOK || string(body) != "data" {
+		t.Fatalf("unexpected download %d: %s", response.StatusCode, body)
+	}
+}
+
+func TestFileTopicsRequireUploader(t *testing.T) {
+	fakeServer := newFakeServer(t, withRoleTokens())
+	viewer, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("viewer-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewer.Close()
+	for _, topic := range []string{fileservice.FilesTopic, fileservice.FileTopic("42")} {
+		if err := viewer.Subscribe(topic); err == nil || err.Error() != streaming.ErrorAccessIsDenied.Error() {
+			t.Fatalf("viewer subscribed to the topic [%s]: %v", topic, err)
+		}
+	}
+	if err := viewer.Subscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+
+	uploader, err := connectFakeClientWithHeader(t, fakeServer, bearerHeader("uploader-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer uploader.Close()
+	if err := uploader.Subscribe(fileservice.FilesTopic); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHttpUploadIsOwnedByPrincipal(t *testing.T) {
+	fakeServer := newFakeServer(t, withRoleTokens())
+	response, body := doHttpRequest(t, http.MethodPut, fakeServer.TestServer.URL+"/files/owned.txt", []byte("data"), bearerHeader("uploader-token"))
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected upload status %d: %s", response.StatusCode, body)
+	}
+	uploaded := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+	record, err := fakeServer.Catalog.Get(uploaded.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Owner != "uploader" {
+		t.Fatalf("file isn't owned by the principal: %q", record.Owner)
+	}
+}
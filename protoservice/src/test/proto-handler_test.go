@@ -0,0 +1,47 @@
+package test
+
+import (
+	"errors"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTypedProtoHandler(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	streaming.HandleProto(fakeServer.WebsocketEngine, "/typed", func(context *streaming.Context, request *fileservice.FileInfoRequest) (*fileservice.FileInfoResponce, error) {
+		if request.GetFileId() == "missing" {
+			return nil, errors.New("Error: missing")
+		}
+		return &fileservice.FileInfoResponce{FileId: request.GetFileId(), FileName: "typed.txt"}, nil
+	})
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	responce, err := fakeClient.Request("/typed", &fileservice.FileInfoRequest{FileId: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := new(fileservice.FileInfoResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), info); err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != "" || info.GetFileId() != "42" || info.GetFileName() != "typed.txt" {
+		t.Fatalf("unexpected responce: %v %v", responce, info)
+	}
+	if responce, err = fakeClient.Request("/typed", &fileservice.FileInfoRequest{FileId: "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != "Error: missing" || len(responce.GetFrame()) != 0 {
+		t.Fatalf("unexpected error responce: %v", responce)
+	}
+	// the request is validated before the handler is called
+	if responce, err = fakeClient.Request("/typed", &fileservice.FileInfoRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != fileservice.ErrorFileIdIsntValid.Error() {
+		t.Fatalf("invalid request reached the handler: %v", responce)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"bytes"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+	"time"
+)
+
+func handlePing(fakeServer *FakeServer) {
+	fakeServer.WebsocketEngine.Handle("/ping", func(context *streaming.Context) {
+		context.Reply(&fileservice.FileInfoResponce{})
+	})
+}
+
+func ping(t *testing.T, fakeClient *FakeClient, uri string, payload []byte) string {
+	responce, err := fakeClient.Request(uri, &fileservice.FileInfoRequest{FileId: string(payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return responce.GetError()
+}
+
+func TestRateLimitRejectsMessagesOverURILimit(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		URIRateLimits: map[streaming.URI]streaming.RateLimit{
+			"/ping": {MessagesPerSecond: 1, MessagesBurst: 2, Action: streaming.RateLimitReject},
+		},
+	}))
+	handlePing(fakeServer)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := ping(t, fakeClient, "/ping", nil); err != "" {
+			t.Fatalf("message within the burst is rejected: %s", err)
+		}
+	}
+	if err := ping(t, fakeClient, "/ping", nil); err != streaming.ErrorRateLimitExceeded.Error() {
+		t.Fatalf("message over the limit isn't rejected: %q", err)
+	}
+	// other URIs and the connection itself aren't affected
+	if _, err := fakeClient.OpenSession("limited.txt", 0, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := fakeServer.WebsocketEngine.Metrics(); metrics.RateLimitedMessages != 1 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestRateLimitDelaysMessages(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		RateLimit:       streaming.RateLimit{MessagesPerSecond: 20, MessagesBurst: 1, Action: streaming.RateLimitDelay},
+	}))
+	handlePing(fakeServer)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	started := time.Now()
+	for attempt := 0; attempt < 5; attempt++ {
+		if err := ping(t, fakeClient, "/ping", nil); err != "" {
+			t.Fatalf("delayed message is rejected: %s", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 150*time.Millisecond {
+		t.Fatalf("messages aren't delayed: %s", elapsed)
+	}
+}
+
+func TestRateLimitDisconnectsOnBytes(t *testing.T) {
+	fakeServer := newFakeServer(t, withEngineConfig(streaming.Config{
+		PoolSizeClients: 5,
+		RateLimit:       streaming.RateLimit{BytesPerSecond: 100, BytesBurst: 1000, Action: streaming.RateLimitDisconnect},
+	}))
+	handlePing(fakeServer)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	payload := bytes.Repeat([]byte("x"), 600)
+	if err := ping(t, fakeClient, "/ping", payload); err != "" {
+		t.Fatalf("message within the burst is rejected: %s", err)
+	}
+	if err := ping(t, fakeClient, "/ping", payload); err != streaming.ErrorRateLimitExceeded.Error() {
+		t.Fatalf("message over the limit isn't rejected: %q", err)
+	}
+	if _, err := fakeClient.Receive(); err == nil {
+		t.Fatal("connection of the client over the limit isn't closed")
+	}
+}
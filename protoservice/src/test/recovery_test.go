@@ -0,0 +1,31 @@
+package test
+
+import (
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+)
+
+func TestHandlerPanicIsRecovered(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeServer.WebsocketEngine.Handle("/panic", func(context *streaming.Context) {
+		panic("handler is broken")
+	})
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	responce, err := fakeClient.Request("/panic", &fileservice.FileInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != streaming.ErrorInternal.Error() {
+		t.Fatalf("unexpected error of the panicked handler: %q", responce.GetError())
+	}
+	if metrics := fakeServer.WebsocketEngine.Metrics(); metrics.RecoveredPanics != 1 {
+		t.Fatalf("panic isn't counted: %+v", metrics)
+	}
+	// the connection and the process survive the panic
+	if _, err := fakeClient.OpenSession("after-panic.txt", 0, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"protoservice/src/fileservice"
+	"testing"
+	"time"
+)
+
+func TestUploadIsResumedAfterReconnect(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+
+	file := bytes.Repeat([]byte("0123456789"), 100)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	handshake, err := fakeClient.Handshake(&fileservice.HandshakeRequest{
+		FileName:    "digits.txt",
+		FileSize:    uint64(len(file)),
+		ContentType: "text/plain",
+		Resumable:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionUUID := handshake.GetSessionUuid()
+	resumeToken := handshake.GetResumeToken()
+	for offset := 0; offset < 400; offset += 100 {
+		if _, err := fakeClient.SendFileFrameAt(sessionUUID, uint64(offset), file[offset:offset+100], false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeClient.Close()
+
+	fakeClient = newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	if err := waitSessionStatus(fakeClient, sessionUUID, resumeToken, func(status *fileservice.SessionStatusResponce) bool {
+		return status.GetPaused()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := fakeClient.ResumeSession(sessionUUID, resumeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.GetCommittedBytes() != 400 {
+		t.Fatalf("unexpected committed bytes after reattach: %d", resumed.GetCommittedBytes())
+	}
+	status, err := fakeClient.SessionStatus(sessionUUID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.GetCommittedBytes() != 400 || status.GetFileSize() != uint64(len(file)) || status.GetPaused() {
+		t.Fatalf("unexpected session status: %v", status)
+	}
+	// a frame that was already committed is acknowledged without being written twice
+	responce, err := fakeClient.SendFileFrameAt(sessionUUID, 300, file[300:400], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetCommittedBytes() != 400 {
+		t.Fatalf("duplicated frame changed committed bytes: %d", responce.GetCommittedBytes())
+	}
+	if err := fakeClient.SendFileFrom(sessionUUID, file, int(status.GetCommittedBytes()), 250); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := storage.Open(sessionUUID + "/digits.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	stored, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, file) {
+		t.Fatal("resumed upload differs from the sent file")
+	}
+}
+
+func TestFrameWithGapIsRejected(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	handshake, err := fakeClient.OpenSession("gap.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responce, err := fakeClient.SendFileFrameAt(handshake.GetSessionUuid(), 10, []byte("frame"), false)
+	if err == nil || err.Error() != fileservice.ErrorFrameOffsetMismatch.Error() {
+		t.Fatalf("frame with a gap is accepted: %v", err)
+	}
+	if responce.GetCommittedBytes() != 0 {
+		t.Fatalf("unexpected committed bytes: %d", responce.GetCommittedBytes())
+	}
+}
+
+func waitSessionStatus(fakeClient *FakeClient, sessionUUID, resumeToken string, condition func(status *fileservice.SessionStatusResponce) bool) error {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := fakeClient.SessionStatus(sessionUUID, resumeToken)
+		if err == nil && condition(status) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.New("session status condition isn't reached")
+			}
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNonResumableSessionIsAbortedOnDisconnect(t *testing.T) {
+	storage := fileservice.NewMemoryStorage()
+	fakeServer := newFakeServer(t, withStorage(storage))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	handshake, err := fakeClient.OpenSession("dropped.txt", 0, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fakeClient.SendFileFrame(handshake.GetSessionUuid(), []byte("partial"), false); err != nil {
+		t.Fatal(err)
+	}
+	fakeClient.Close()
+
+	fakeClient = newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := fakeClient.SessionStatus(handshake.GetSessionUuid(), handshake.GetResumeToken()); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session of the dropped connection isn't aborted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := fakeClient.ResumeSession(handshake.GetSessionUuid(), handshake.GetResumeToken()); err == nil {
+		t.Fatal("aborted session is reattached")
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFakeServerFailsOnConflictingRoutes(t *testing.T) {
+	engine := streaming.NewEngine(5, 100)
+	if err := engine.Handle("/file/get", func(context *streaming.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFakeServer(engine, fileservice.NewMemoryStorage(), nil, fileservice.SessionTimeouts{}); err != streaming.ErrorHandlerIsExist {
+		t.Fatalf("file service is started over a registered route: %v", err)
+	}
+	if err := engine.Handle("/file/info", func(context *streaming.Context) {}); err != nil {
+		t.Fatalf("routes after the failed one are registered: %v", err)
+	}
+}
+
+func TestRouterCapturesParams(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	engine := fakeServer.WebsocketEngine
+	reply := func(context *streaming.Context) {
+		context.Reply(&fileservice.FileInfoResponce{
+			FileId:   context.Param("id") + context.Param("path"),
+			FileName: string(context.URI),
+		})
+	}
+	bucket := engine.Group("/bucket", func(context *streaming.Context) {
+		context.Params["group"] = "bucket"
+	})
+	if err := bucket.Handle("/*path", func(context *streaming.Context) {
+		if context.Param("group") != "bucket" {
+			context.ReplyError(streaming.ErrorInternal)
+			return
+		}
+		reply(context)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Handle("/item/:id/meta", reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Handle("/item/latest/meta", func(context *streaming.Context) {
+		context.Reply(&fileservice.FileInfoResponce{FileId: "latest"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Handle("/item/:id/meta", reply); err != streaming.ErrorHandlerIsExist {
+		t.Fatalf("duplicate route is registered: %v", err)
+	}
+	if err := engine.Handle("/item/:name/data", reply); err != streaming.ErrorRouteIsntValid {
+		t.Fatalf("route with a conflicting parameter is registered: %v", err)
+	}
+	if err := engine.Handle("/send/file", reply); err != streaming.ErrorHandlerIsExist {
+		t.Fatalf("file service route is registered twice: %v", err)
+	}
+	if err := engine.Handle("/draft/:id/*", reply); err != streaming.ErrorRouteIsntValid {
+		t.Fatalf("route with an unnamed wildcard is registered: %v", err)
+	}
+	// the rejected route doesn't leave its ":id" node behind
+	if err := engine.Handle("/draft/:name", reply); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	for uri, expected := range map[string]string{
+		"/item/42/meta":       "42",
+		"/item/latest/meta":   "latest",
+		"/bucket/a/b/c.txt":   "a/b/c.txt",
+		"/bucket/single.json": "single.json",
+	} {
+		responce, err := fakeClient.Request(uri, &fileservice.FileInfoRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := new(fileservice.FileInfoResponce)
+		if err := proto.Unmarshal(responce.GetFrame(), info); err != nil {
+			t.Fatal(err)
+		}
+		if responce.GetError() != "" || info.GetFileId() != expected {
+			t.Fatalf("unexpected responce for [%s]: %v %v", uri, responce, info)
+		}
+	}
+	responce, err := fakeClient.Request("/item/42", &fileservice.FileInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responce.GetError() != streaming.ErrorHandlerIsntExist.Error() {
+		t.Fatalf("partial route is matched: %v", responce)
+	}
+}
+
+func TestFileMetaRoute(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fileID := uploadFile(t, fakeServer, "meta.txt", []byte("meta"))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	responce, err := fakeClient.Request("/file/"+fileID+"/meta", &fileservice.FileInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := new(fileservice.FileInfoResponce)
+	if err := proto.Unmarshal(responce.GetFrame(), info); err != nil {
+		t.Fatal(err)
+	}
+	if info.GetFileName() != "meta.txt" || info.GetFileSize() != 4 {
+		t.Fatalf("unexpected file meta: %v %v", responce, info)
+	}
+}
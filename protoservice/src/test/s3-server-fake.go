@@ -0,0 +1,210 @@
+package test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeS3Object struct {
+	content  []byte
+	modified time.Time
+}
+
+// FakeS3Server serves the part of the S3 API used by S3Storage: buckets,
+// multipart uploads, ranged reads, listing and deleting of path-style
+// objects. Requests aren't authenticated.
+type FakeS3Server struct {
+	TestServer *httptest.Server
+	buckets    map[string]map[string]fakeS3Object
+	uploads    map[string]map[int][]byte
+	mx         *sync.Mutex
+}
+
+func NewFakeS3Server() *FakeS3Server {
+	this := new(FakeS3Server)
+	this.buckets = make(map[string]map[string]fakeS3Object)
+	this.uploads = make(map[string]map[int][]byte)
+	this.mx = new(sync.Mutex)
+	this.TestServer = httptest.NewServer(http.HandlerFunc(this.serve))
+	return this
+}
+
+// Endpoint is the "host:port" of the server as S3 clients expect it.
+func (this *FakeS3Server) Endpoint() string {
+	return strings.TrimPrefix(this.TestServer.URL, "http://")
+}
+
+func (this *FakeS3Server) Close() {
+	this.TestServer.Close()
+}
+
+func (this *FakeS3Server) serve(w http.ResponseWriter, r *http.Request) {
+	this.mx.Lock()
+	defer this.mx.Unlock()
+	path := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucketName, key := path[0], ""
+	if len(path) == 2 {
+		key = path[1]
+	}
+	query := r.URL.Query()
+	if key == "" {
+		this.serveBucket(w, r, bucketName, query)
+		return
+	}
+	bucket, exist := this.buckets[bucketName]
+	if !exist {
+		writeFakeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := uuid.New().String()
+		this.uploads[uploadID] = make(map[int][]byte)
+		writeFakeS3XML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucketName, Key: key, UploadId: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, exist := this.uploads[query.Get("uploadId")]
+		if !exist {
+			writeFakeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		content, _ := ioutil.ReadAll(r.Body)
+		parts[partNumber] = content
+		w.Header().Set("ETag", fakeS3ETag(content))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, exist := this.uploads[query.Get("uploadId")]
+		if !exist {
+			writeFakeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		completion := struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}{}
+		if err := xml.NewDecoder(r.Body).Decode(&completion); err != nil {
+			writeFakeS3Error(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		content := bytes.NewBuffer(nil)
+		for _, part := range completion.Parts {
+			content.Write(parts[part.PartNumber])
+		}
+		delete(this.uploads, query.Get("uploadId"))
+		bucket[key] = fakeS3Object{content: content.Bytes(), modified: time.Now()}
+		writeFakeS3XML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucketName, Key: key, ETag: fakeS3ETag(content.Bytes())})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(this.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, exist := bucket[key]
+		if !exist {
+			writeFakeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", fakeS3ETag(object.content))
+		w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, key, object.modified, bytes.NewReader(object.content))
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (this *FakeS3Server) serveBucket(w http.ResponseWriter, r *http.Request, bucketName string, query url.Values) {
+	bucket, exist := this.buckets[bucketName]
+	switch {
+	case r.Method == http.MethodPut:
+		if !exist {
+			this.buckets[bucketName] = make(map[string]fakeS3Object)
+		}
+	case !exist:
+		writeFakeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+	case r.Method == http.MethodHead:
+	case r.Method == http.MethodGet && query.Has("location"):
+		writeFakeS3XML(w, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+		}{})
+	case r.Method == http.MethodGet && query.Has("list-type"):
+		prefix := query.Get("prefix")
+		type content struct {
+			Key          string
+			LastModified string
+			ETag         string
+			Size         int64
+		}
+		contents := make([]content, 0)
+		for key, object := range bucket {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			contents = append(contents, content{
+				Key:          key,
+				LastModified: object.modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+				ETag:         fakeS3ETag(object.content),
+				Size:         int64(len(object.content)),
+			})
+		}
+		sort.Slice(contents, func(i, j int) bool {
+			return contents[i].Key < contents[j].Key
+		})
+		writeFakeS3XML(w, struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Name        string
+			Prefix      string
+			KeyCount    int
+			IsTruncated bool
+			Contents    []content
+		}{Name: bucketName, Prefix: prefix, KeyCount: len(contents), Contents: contents})
+	default:
+		writeFakeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func fakeS3ETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeFakeS3XML(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(value)
+}
+
+func writeFakeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message><Resource>%s</Resource></Error>", xml.Header, code, code, r.URL.Path)
+}
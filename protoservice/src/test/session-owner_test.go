@@ -0,0 +1,110 @@
+package test
+
+import (
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func TestFramesOfAnotherClientAreRejected(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	owner := newConnectedFakeClient(t, fakeServer)
+	defer owner.Close()
+	intruder := newConnectedFakeClient(t, fakeServer)
+	defer intruder.Close()
+
+	handshake, err := owner.OpenSession("owned.txt", 10, "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := owner.SendFileFrame(handshake.GetSessionUuid(), []byte("01234"), false); err != nil {
+		t.Fatal(err)
+	}
+	_, err = intruder.SendFileFrame(handshake.GetSessionUuid(), []byte("xxxxx"), false)
+	if err == nil || err.Error() != fileservice.ErrorSessionOwnerMismatch.Error() {
+		t.Fatalf("frame of another client is accepted: %v", err)
+	}
+	if _, err := intruder.SessionStatus(handshake.GetSessionUuid(), ""); err == nil {
+		t.Fatal("status of the session is available to another client")
+	}
+	responce, err := owner.SendFileFrame(handshake.GetSessionUuid(), []byte("56789"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !responce.GetOk() {
+		t.Fatal("owner can't finish the upload after a rejected frame")
+	}
+	if _, err := fakeServer.Catalog.Get(handshake.GetSessionUuid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionIsReattachedOnlyWithResumeToken(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	owner := newConnectedFakeClient(t, fakeServer)
+	handshake, err := owner.Handshake(&fileservice.HandshakeRequest{
+		FileName:  "resumable.txt",
+		Resumable: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handshake.GetResumeToken() == "" {
+		t.Fatal("resume token isn't issued")
+	}
+	owner.Close()
+
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	if err := waitSessionStatus(fakeClient, handshake.GetSessionUuid(), handshake.GetResumeToken(), func(status *fileservice.SessionStatusResponce) bool {
+		return status.GetPaused()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fakeClient.ResumeSession(handshake.GetSessionUuid(), "")
+	if err == nil || err.Error() != fileservice.ErrorSessionOwnerMismatch.Error() {
+		t.Fatalf("session is reattached without a resume token: %v", err)
+	}
+
+	fakeClient = newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+	if _, err := fakeClient.ResumeSession(handshake.GetSessionUuid(), handshake.GetResumeToken()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActiveSessionIsntReattached(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	owner := newConnectedFakeClient(t, fakeServer)
+	defer owner.Close()
+	handshake, err := owner.Handshake(&fileservice.HandshakeRequest{
+		FileName:  "resumable.txt",
+		Resumable: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intruder := newConnectedFakeClient(t, fakeServer)
+	defer intruder.Close()
+	_, err = intruder.ResumeSession(handshake.GetSessionUuid(), handshake.GetResumeToken())
+	if err == nil || err.Error() != fileservice.ErrorSessionIsntPaused.Error() {
+		t.Fatalf("session of a connected client is reattached: %v", err)
+	}
+	if _, err := owner.SendFileFrame(handshake.GetSessionUuid(), []byte("01234"), false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandshakeOnBehalfOfAnotherClientIsRejected(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	_, err := fakeClient.Handshake(&fileservice.HandshakeRequest{
+		RemoteAddress: "10.0.0.1:4242",
+		FileName:      "spoofed.txt",
+	})
+	if err == nil || err.Error() != fileservice.ErrorSessionOwnerMismatch.Error() {
+		t.Fatalf("handshake with a spoofed remote address is accepted: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"protoservice/src/fileservice"
+	"testing"
+	"time"
+)
+
+func TestIdleSessionIsReaped(t *testing.T) {
+	rootPath := t.TempDir()
+	fakeServer := newFakeServer(t, withStorage(fileservice.NewLocalStorage(rootPath, "storage")), withSessionTimeouts(fileservice.SessionTimeouts{
+		Idle:        100 * time.Millisecond,
+		MaxLifetime: time.Hour,
+	}))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	handshake, err := fakeClient.OpenSession("abandoned.bin", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fakeClient.SendFileFrame(handshake.GetSessionUuid(), []byte("partial data"), false); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(400 * time.Millisecond)
+
+	if _, err := fakeClient.SessionStatus(handshake.GetSessionUuid(), ""); err == nil {
+		t.Fatal("expired session is still available")
+	}
+	partialFiles, _ := filepath.Glob(filepath.Join(rootPath, "storage", handshake.GetSessionUuid(), "*"))
+	if len(partialFiles) != 0 {
+		t.Fatalf("partial data of the expired session isn't removed: %v", partialFiles)
+	}
+	if _, err := os.Stat(filepath.Join(rootPath, "storage", handshake.GetSessionUuid(), "abandoned.bin")); !os.IsNotExist(err) {
+		t.Fatal("expired session is finalized")
+	}
+}
+
+func TestSessionIsReapedAfterMaxLifetime(t *testing.T) {
+	fakeServer := newFakeServer(t, withSessionTimeouts(fileservice.SessionTimeouts{
+		Idle:        time.Hour,
+		MaxLifetime: 200 * time.Millisecond,
+	}))
+	fakeClient := newConnectedFakeClient(t, fakeServer)
+	defer fakeClient.Close()
+
+	handshake, err := fakeClient.OpenSession("slow.bin", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := fakeClient.SendFileFrame(handshake.GetSessionUuid(), []byte("x"), false)
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session outlived its max lifetime")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"protoservice/src/fileservice"
+	"testing"
+)
+
+func testStorage(t *testing.T, storage fileservice.Storage) {
+	writer, err := storage.Create("directory/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("hello, ")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Stat("directory/file.txt"); err != fileservice.ErrorFileIsntExist {
+		t.Fatalf("file is visible before commit: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	aborted, err := storage.Create("directory/aborted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aborted.Write([]byte("aborted"))
+	if err := aborted.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := storage.Stat("directory/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len("hello, world")) {
+		t.Fatalf("unexpected size %d", info.Size)
+	}
+	reader, err := storage.Open("directory/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello, world" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	files, err := storage.List("directory/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "directory/file.txt" {
+		t.Fatalf("unexpected listing %v", files)
+	}
+	if _, err := storage.Create("../escape.txt"); err != fileservice.ErrorFileNameIsntValid {
+		t.Fatalf("name escaping the storage is accepted: %v", err)
+	}
+	if err := storage.Delete("directory/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Open("directory/file.txt"); err != fileservice.ErrorFileIsntExist {
+		t.Fatalf("deleted file is still readable: %v", err)
+	}
+	if err := storage.Delete("directory/file.txt"); err != fileservice.ErrorFileIsntExist {
+		t.Fatalf("deleting a missing file returned: %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, fileservice.NewLocalStorage(t.TempDir(), "storage"))
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, fileservice.NewMemoryStorage())
+}
+
+func TestLocalStorageListsPartFiles(t *testing.T) {
+	storage := fileservice.NewLocalStorage(t.TempDir(), "storage")
+	writer, err := storage.Create("video.part")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := storage.Create("pending.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pending.Abort()
+	writer.Write([]byte("part"))
+	if err := writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := storage.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "video.part" {
+		t.Fatalf("unexpected listing %v", files)
+	}
+	if _, err := storage.Create("directory/.video.part"); err != fileservice.ErrorFileNameIsntValid {
+		t.Fatalf("name reserved for temporary files is accepted: %v", err)
+	}
+}
+
+// TestS3Storage runs against an in-process FakeS3Server, or against a MinIO
+// server given by FILESERVICE_TEST_S3_ENDPOINT, e.g. "localhost:9000" with
+// the default "minioadmin" credentials.
+func TestS3Storage(t *testing.T) {
+	endpoint := os.Getenv("FILESERVICE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		server := NewFakeS3Server()
+		defer server.Close()
+		endpoint = server.Endpoint()
+	}
+	accessKey, secretKey := os.Getenv("FILESERVICE_TEST_S3_ACCESS_KEY"), os.Getenv("FILESERVICE_TEST_S3_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = "minioadmin", "minioadmin"
+	}
+	storage, err := fileservice.NewS3Storage(endpoint, accessKey, secretKey, "fileservice-test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, storage)
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"net/http"
+	"protoservice/src/fileservice"
+	"protoservice/src/streaming"
+	"testing"
+	"time"
+)
+
+func TestWatchersAreNotifiedAboutFiles(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	watcher := newConnectedFakeClient(t, fakeServer)
+	defer watcher.Close()
+	if err := watcher.Subscribe(fileservice.FilesTopic); err != nil {
+		t.Fatal(err)
+	}
+
+	fileID := uploadFile(t, fakeServer, "watched.txt", []byte("watched"))
+	event := new(fileservice.FileEvent)
+	if err := watcher.ReceivePublication(fileservice.FilesTopic, event, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if event.GetKind() != fileservice.FileEvent_UPLOADED || event.GetFile().GetFileId() != fileID || event.GetFile().GetFileName() != "watched.txt" {
+		t.Fatalf("unexpected upload event: %v", event)
+	}
+
+	if err := watcher.Subscribe(fileservice.FileTopic(fileID)); err != nil {
+		t.Fatal(err)
+	}
+	if response, _ := doHttpRequest(t, http.MethodDelete, fakeServer.TestServer.URL+"/files/"+fileID, nil, nil); response.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected delete status: %d", response.StatusCode)
+	}
+	event = new(fileservice.FileEvent)
+	if err := watcher.ReceivePublication(fileservice.FileTopic(fileID), event, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if event.GetKind() != fileservice.FileEvent_DELETED || event.GetFile().GetFileId() != fileID {
+		t.Fatalf("unexpected delete event: %v", event)
+	}
+}
+
+func TestPublishReachesOnlySubscribers(t *testing.T) {
+	fakeServer := newFakeServer(t)
+	subscriber := newConnectedFakeClient(t, fakeServer)
+	defer subscriber.Close()
+	unsubscribed := newConnectedFakeClient(t, fakeServer)
+	defer unsubscribed.Close()
+	if err := subscriber.Subscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unsubscribed.Subscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unsubscribed.Unsubscribe("news"); err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriber.Subscribe(""); err == nil || err.Error() != streaming.ErrorTopicIsEmpty.Error() {
+		t.Fatalf("subscription to an empty topic is accepted: %v", err)
+	}
+
+	delivered, err := fakeServer.WebsocketEngine.Publish("news", &fileservice.FileInfoResponce{FileId: "headline"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 {
+		t.Fatalf("unexpected number of deliveries: %d", delivered)
+	}
+	message := new(fileservice.FileInfoResponce)
+	if err := subscriber.ReceivePublication("news", message, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if message.GetFileId() != "headline" {
+		t.Fatalf("unexpected publication: %v", message)
+	}
+
+	// subscriptions of a closed connection are dropped
+	subscriber.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		delivered, err = fakeServer.WebsocketEngine.Publish("news", &fileservice.FileInfoResponce{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivered == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("closed connection is still subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}